@@ -2,6 +2,7 @@ package kdl_test
 
 import (
 	"io"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -139,3 +140,77 @@ func BenchmarkFormat(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkEmitSynthetic emits a large generated document with deep nesting and
+// strings that need quoting and escaping, which the parsed inputs lack.
+func BenchmarkEmitSynthetic(b *testing.B) {
+	doc := kdl.NewDocument()
+	for i := range 200 {
+		n := kdl.NewNode("section").
+			WithTypeAnnotation("group").
+			AddArgument(kdl.NewString("title with spaces")).
+			AddProperty("path", kdl.NewString(`C:\dir\"quoted"`+"\tand\nlines")).
+			AddProperty("id", kdl.NewInt(i))
+		parent := n
+		for range 8 {
+			parent = parent.NewChild("level").AddArgument(kdl.NewFloat(1.5)).AddProperty("plain", kdl.NewString("bare"))
+		}
+		doc.AddNode(n)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := kdl.Emit(doc, io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEmitWideNode emits a single node with 10k arguments and properties.
+func BenchmarkEmitWideNode(b *testing.B) {
+	n := kdl.NewNode("wide")
+	for i := range 10_000 {
+		n.AddArgument(kdl.NewInt(i))
+		n.AddProperty("k"+strconv.Itoa(i), kdl.NewString("value"))
+	}
+	doc := kdl.NewDocument(n)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := kdl.Emit(doc, io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// writerOnly hides any WriteString method of the wrapped writer, forcing
+// Emit's writes through a []byte conversion.
+type writerOnly struct{ io.Writer }
+
+// BenchmarkEmitWriters compares emitting into a strings.Builder, which
+// receives the output through WriteString without copies, with a writer that
+// only implements Write.
+func BenchmarkEmitWriters(b *testing.B) {
+	parsed, err := kdl.Parse(strings.NewReader(benchMedium))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Builder", func(b *testing.B) {
+		var sb strings.Builder
+		b.ReportAllocs()
+		for b.Loop() {
+			sb.Reset()
+			if err := kdl.Emit(parsed, &sb); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Writer", func(b *testing.B) {
+		var sb strings.Builder
+		b.ReportAllocs()
+		for b.Loop() {
+			sb.Reset()
+			if err := kdl.Emit(parsed, writerOnly{&sb}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
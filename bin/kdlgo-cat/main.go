@@ -19,12 +19,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	f, err := os.Open(flag.Arg(0))
-	if err != nil {
-		panic(err)
-	}
-
-	doc, err := kdl.Parse(f)
+	doc, err := kdl.ReadFile(flag.Arg(0))
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/calico32/kdl-go"
+)
+
+// The JSON format kdl-test expects from a decoder. This is distinct from the
+// general-purpose mapping implemented by kdl.Document.MarshalJSON.
+
+type testNode struct {
+	Name     string               `json:"name"`
+	Type     *string              `json:"type"`
+	Args     []testValue          `json:"args"`
+	Props    map[string]testValue `json:"props"`
+	Children []testNode           `json:"children"`
+}
+
+type testValue struct {
+	Type  *string           `json:"type"`
+	Value map[string]string `json:"value"`
+}
+
+func toTestNodes(nodes []*kdl.Node) []testNode {
+	out := make([]testNode, 0, len(nodes))
+	for _, n := range nodes {
+		tn := testNode{
+			Name:     n.Name(),
+			Args:     make([]testValue, 0, len(n.Arguments())),
+			Props:    make(map[string]testValue, len(n.Properties())),
+			Children: toTestNodes(n.Children().Nodes),
+		}
+		if ty, ok := n.TypeAnnotation(); ok {
+			tn.Type = &ty
+		}
+		for _, v := range n.Arguments() {
+			tn.Args = append(tn.Args, toTestValue(v))
+		}
+		for k, v := range n.Properties() {
+			tn.Props[k] = toTestValue(v)
+		}
+		out = append(out, tn)
+	}
+	return out
+}
+
+func toTestValue(v kdl.Value) testValue {
+	var tv testValue
+	if ty, ok := v.TypeAnnotation(); ok {
+		tv.Type = &ty
+	}
+	switch v.Kind() {
+	case kdl.String:
+		tv.Value = map[string]string{"type": "string", "value": v.String()}
+	case kdl.Bool:
+		tv.Value = map[string]string{"type": "boolean", "value": fmt.Sprint(v.Bool())}
+	case kdl.Null:
+		tv.Value = map[string]string{"type": "null"}
+	case kdl.Int:
+		tv.Value = map[string]string{"type": "number", "value": fmt.Sprintf("%d.0", v.Int())}
+	case kdl.Float:
+		str := fmt.Sprintf("%f", v.Float())
+		if math.IsInf(v.Float(), 1) {
+			str = "inf"
+		} else if math.IsInf(v.Float(), -1) {
+			str = "-inf"
+		} else if math.IsNaN(v.Float()) {
+			str = "nan"
+		} else {
+			// remove extra trailing zeros (e.g. "1.000" -> "1.0")
+			str = strings.TrimRight(str, "0")
+			if str[len(str)-1] == '.' {
+				str += "0"
+			}
+		}
+		tv.Value = map[string]string{"type": "number", "value": str}
+	case kdl.BigInt:
+		tv.Value = map[string]string{"type": "number", "value": fmt.Sprintf("%d.0", v.BigInt())}
+	case kdl.BigFloat:
+		str := v.BigFloat().Text('f', -1)
+		// add ".0" if needed
+		if !strings.ContainsAny(str, ".") {
+			str += ".0"
+		}
+		tv.Value = map[string]string{"type": "number", "value": str}
+	}
+	return tv
+}
@@ -14,7 +14,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	output, err := json.MarshalIndent(doc, "", "  ")
+	output, err := json.MarshalIndent(toTestNodes(doc.Nodes), "", "  ")
 	if err != nil {
 		os.Stderr.WriteString(err.Error() + "\n")
 		os.Exit(1)
@@ -1,5 +1,7 @@
 package kdl
 
+import "strings"
+
 // CommentKind is a kind of KDL comment.
 type CommentKind int
 
@@ -118,3 +120,38 @@ func (s InlineSlashdash) PropKeyLocation() (start, end Location, ok bool) {
 	}
 	return s.propKeyStart, s.propKeyEnd, true
 }
+
+// commentLines splits text into lines for writing as // comments, breaking on
+// any KDL newline so that no line can end the comment early. Each line is
+// returned with its "//" prefix.
+func commentLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var lines []string
+	start := 0
+	for i, ch := range text {
+		if isNewline(ch) {
+			lines = append(lines, commentLine(text[start:i]))
+			start = i + len(string(ch))
+		}
+	}
+	return append(lines, commentLine(text[start:]))
+}
+
+func commentLine(s string) string {
+	if s == "" {
+		return "//"
+	}
+	return "// " + s
+}
+
+// singleLineComment replaces any KDL newlines in text with spaces so that it
+// fits in a single // comment.
+func singleLineComment(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Map(func(ch rune) rune {
+		if isNewline(ch) {
+			return ' '
+		}
+		return ch
+	}, text)
+}
@@ -11,7 +11,8 @@ import (
 
 // A decoder is a KDL unmarshaler.
 type decoder struct {
-	strict bool
+	strict          bool
+	disallowUnknown bool
 }
 
 // unmarshalDocument unmarshals a KDL document into the given Go value v.
@@ -9,6 +9,10 @@ var (
 	// ErrStrict is the base error type for strict mode errors. It can be
 	// used with [errors.Is] to check for strict mode errors.
 	ErrStrict = fmt.Errorf("strict mode error")
+
+	// ErrUnknownNode is returned when decoding with [WithDisallowUnknownNodes]
+	// and a node does not map to any struct field.
+	ErrUnknownNode = fmt.Errorf("unknown node")
 )
 
 // Decode reads a KDL document from r and unmarshals it into v. If v implements
@@ -62,14 +66,37 @@ var (
 //   - child: indicates that the field should only match child nodes, not
 //     properties.
 //   - children: indicates that the field should receive any child nodes not
-//     mapped to other fields. Valid only on slice, array, or map types. Can only
-//     be used once per struct.
+//     mapped to other fields. Valid only on slice, map, or struct types. A
+//     []kdl.Node or []*kdl.Node field receives the nodes themselves, and any
+//     other slice receives each node unmarshaled into an element. Can only be
+//     used once per struct.
 //   - presence: indicates that for bool fields, the presence of a child node
 //     with no arguments is interpreted as true. Valid only on bool fields.
 //
 // An additional tag, omitzero, can be used to control marshaling behavior but
 // is ignored during unmarshaling.
 //
+// A slice of [value type] elements can be filled in one of four ways, chosen by
+// its tag:
+//   - `kdl:"tags"` fills it from the arguments of a single node, as in
+//     `tags a b c`;
+//   - `kdl:"host,multiple"` fills it from several nodes with the same name, one
+//     element per node, as in `host one; host two`;
+//   - `kdl:",args"` fills it from the arguments of the node the struct itself
+//     is decoded from that are not mapped to other fields;
+//   - `kdl:",children"` fills it from the child nodes of that node that are not
+//     mapped to other fields, one element per child, whatever its name.
+//
+// The fields of an embedded struct (or pointer to struct) without a kdl tag are
+// promoted into the outer struct, as with encoding/json, so they are matched
+// against the same node's arguments, properties, and children. If a promoted
+// field has the same name as a less deeply embedded one, the outer field wins.
+// If several fields with the same name are equally deep, the one with a kdl
+// tag wins, and if there is no single such field, all of them are ignored.
+// Embedded types with their own unmarshaling methods, and [time.Time], are not
+// promoted. An embedded struct is not matched against a child node named after
+// its type unless it has a tag saying so, such as `kdl:"CommonFields"`.
+//
 // For example:
 //
 //	type Config struct {
@@ -85,6 +112,15 @@ var (
 //	    Internal bool              `kdl:"internal,presence"`
 //	}
 //
+// # Validation
+//
+// After a struct has been decoded, Decode calls its Validate method if the
+// struct or a pointer to it implements [Validator]. Nested structs are decoded,
+// and so validated, before the struct containing them. Decoding stops at the
+// first error from Validate, which is returned wrapped with the location and
+// path of the node the struct was decoded from, so [errors.Is] and [errors.As]
+// still see the original error.
+//
 // # Values
 //
 // A [value type] is any of:
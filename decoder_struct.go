@@ -21,7 +21,7 @@ func (d *decoder) unmarshalNodesIntoStructFields(nodes []*Node, target reflect.V
 	}
 
 	for _, node := range nodes {
-		usedFieldIndex, err := d.unmarshalNodeIntoStructField(node, ctx.tags, target)
+		usedFieldIndex, err := d.unmarshalNodeIntoStructField(node, ctx, target)
 		if err != nil {
 			return err
 		}
@@ -31,11 +31,13 @@ func (d *decoder) unmarshalNodesIntoStructFields(nodes []*Node, target reflect.V
 			ctx.markFieldUsed(usedFieldIndex)
 		} else if d.strict {
 			return fmt.Errorf("%w: no matching field found for node %q", ErrStrict, node.name)
+		} else if d.disallowUnknown {
+			return unknownNodeError(node)
 		}
 	}
 
 	// TODO: decide if this condition is desirable, also outside strict mode?
-	if noneFound && len(nodes) > 0 && target.Type().NumField() > 0 {
+	if noneFound && len(nodes) > 0 && len(ctx.tags) > 0 {
 		return fmt.Errorf("%s: no matching fields found for any nodes unmarshaling into struct %s", nodes[0].loc, target.Type())
 	}
 
@@ -63,6 +65,9 @@ func (d *decoder) unmarshalNodesIntoStructFields(nodes []*Node, target reflect.V
 		return fmt.Errorf("%w: missing values for struct fields: %s", ErrStrict, sb.String())
 	}
 
+	if err := validateStruct(target); err != nil {
+		return fmt.Errorf("validating struct %s: %w", target.Type(), err)
+	}
 	return nil
 }
 
@@ -71,18 +76,17 @@ func (d *decoder) unmarshalNodesIntoStructFields(nodes []*Node, target reflect.V
 // a matching field is found, the node is unmarshaled into that field and the
 // index of the field is returned. If no matching field is found, -1 is
 // returned.
-func (d *decoder) unmarshalNodeIntoStructField(node *Node, tags []structTag, target reflect.Value) (index int, err error) {
+func (d *decoder) unmarshalNodeIntoStructField(node *Node, ctx *structContext, target reflect.Value) (index int, err error) {
 	nodeName := node.name
 	target = unwrapPointer(target)
 
-	for fieldIndex := range target.Type().NumField() {
-		tag := tags[fieldIndex]
+	for fieldIndex, tag := range ctx.tags {
 		if tag.name == "" || tag.name == "-" {
 			continue
 		}
 
 		if tag.name == nodeName && tag.flags&property == 0 {
-			if err := d.unmarshalNode(node, tag, target.Field(fieldIndex)); err != nil {
+			if err := d.unmarshalNode(node, tag, ctx.field(target, fieldIndex)); err != nil {
 				return fieldIndex, err
 			}
 			return fieldIndex, nil
@@ -124,7 +128,7 @@ func (d *decoder) unmarshalNodeIntoStruct(node *Node, target reflect.Value) erro
 	}
 
 	for argumentNum, fieldIndex := range ctx.argFields {
-		field := target.Field(fieldIndex)
+		field := ctx.field(target, fieldIndex)
 		if argumentNum >= len(node.args) {
 			if d.strict || ctx.isFieldStrict(fieldIndex) {
 				return fmt.Errorf("%s: expected at least %d arguments (unmarshaling node %q into struct %s)", node.loc, argumentNum+1, node.name, target.Type())
@@ -143,7 +147,7 @@ func (d *decoder) unmarshalNodeIntoStruct(node *Node, target reflect.Value) erro
 	}
 
 	if hasExtraArgs && ctx.argsField != -1 {
-		field := target.Field(ctx.argsField)
+		field := ctx.field(target, ctx.argsField)
 		unusedArguments := node.args[len(ctx.argFields):]
 		if err := d.unmarshalValues(unusedArguments, ctx.tags[ctx.argsField], field); err != nil {
 			return err
@@ -153,11 +157,10 @@ func (d *decoder) unmarshalNodeIntoStruct(node *Node, target reflect.Value) erro
 
 	for propName, propValue := range node.props {
 		found := false
-		for fieldIndex := range target.Type().NumField() {
-			tag := ctx.tags[fieldIndex]
+		for fieldIndex, tag := range ctx.tags {
 			if tag.name == propName && tag.flags&child == 0 {
 				found = true
-				err := d.unmarshalValue(propValue, tag, target.Field(fieldIndex))
+				err := d.unmarshalValue(propValue, tag, ctx.field(target, fieldIndex))
 				if err != nil {
 					return err
 				}
@@ -171,7 +174,7 @@ func (d *decoder) unmarshalNodeIntoStruct(node *Node, target reflect.Value) erro
 	}
 
 	if ctx.propsField != -1 {
-		field := target.Field(ctx.propsField)
+		field := ctx.field(target, ctx.propsField)
 		unusedProperties := make(map[string]Value)
 		for propName, propValue := range node.props {
 			if ctx.isPropertyUnused(propName) {
@@ -185,18 +188,20 @@ func (d *decoder) unmarshalNodeIntoStruct(node *Node, target reflect.Value) erro
 	}
 
 	for childIndex, node := range node.children.Nodes {
-		if usedFieldIndex, err := d.unmarshalNodeIntoStructField(node, ctx.tags, target); err != nil {
+		if usedFieldIndex, err := d.unmarshalNodeIntoStructField(node, ctx, target); err != nil {
 			return err
 		} else if usedFieldIndex != -1 {
 			ctx.markChildUsed(childIndex)
 			ctx.markFieldUsed(usedFieldIndex)
 		} else if ctx.childrenField == -1 && d.strict {
 			return fmt.Errorf("%w: no matching field found for node %q", ErrStrict, node.name)
+		} else if ctx.childrenField == -1 && d.disallowUnknown {
+			return unknownNodeError(node)
 		}
 	}
 
 	if ctx.childrenField != -1 {
-		field := target.Field(ctx.childrenField)
+		field := ctx.field(target, ctx.childrenField)
 		unusedChildren := make([]*Node, 0, len(node.children.Nodes)-len(ctx.usedChildren))
 		for i, child := range node.children.Nodes {
 			if ctx.isChildUnused(i) {
@@ -233,6 +238,21 @@ func (d *decoder) unmarshalNodeIntoStruct(node *Node, target reflect.Value) erro
 		return fmt.Errorf("%w: missing values for strict struct fields: %s", ErrStrict, sb.String())
 	}
 
+	if err := validateStruct(target); err != nil {
+		return fmt.Errorf("%s: validating node %q: %w", node.loc, node.Path(), err)
+	}
+	return nil
+}
+
+// validateStruct calls Validate on the decoded struct target, or on a pointer
+// to it, if either implements [Validator].
+func validateStruct(target reflect.Value) error {
+	if target.CanAddr() {
+		target = target.Addr()
+	}
+	if v, ok := target.Interface().(Validator); ok {
+		return v.Validate()
+	}
 	return nil
 }
 
@@ -352,19 +372,16 @@ func (d *decoder) unmarshalPropertiesField(location Location, properties map[str
 		return nil
 
 	case reflect.Struct:
+		ctx, err := newStructContext(target.Type())
+		if err != nil {
+			return fmt.Errorf("parsing struct tags for struct %s: %w", target.Type(), err)
+		}
 		for propName, propValue := range properties {
 			fieldFound := false
-			ty := target.Type()
-			for i := 0; i < ty.NumField(); i++ {
-				field := ty.Field(i)
-				tagStr := field.Tag.Get("kdl")
-				tag, err := parseStructTag(tagStr)
-				if err != nil {
-					return err
-				}
+			for i, tag := range ctx.tags {
 				if tag.name == propName {
 					fieldFound = true
-					if err := d.unmarshalValue(propValue, tag, target.Field(i)); err != nil {
+					if err := d.unmarshalValue(propValue, tag, ctx.field(target, i)); err != nil {
 						return err
 					}
 					break
@@ -384,7 +401,8 @@ func (d *decoder) unmarshalPropertiesField(location Location, properties map[str
 // unmarshalChildrenField unmarshals a slice of KDL nodes into a Go map, struct,
 // or slice. Behaves as expected for maps using node names as keys. For structs,
 // matches node names to struct field names or tags, returning a strict mode
-// error if any node cannot be matched. For slices, unmarshals nodes in order.
+// error if any node cannot be matched. For slices, unmarshals nodes in order,
+// storing them as-is for []Node and []*Node.
 func (d *decoder) unmarshalChildrenField(children []*Node, tag structTag, target reflect.Value) error {
 	if target.Kind() == reflect.Pointer {
 		elem := target.Type().Elem()
@@ -422,12 +440,8 @@ func (d *decoder) unmarshalChildrenField(children []*Node, tag structTag, target
 		return d.unmarshalNodesIntoStructFields(children, target)
 
 	case reflect.Slice:
-		// only []Node or []*Node are supported for now - doesn't make sense
-		// to encode a []anything as children - each value would need a name,
-		// not clear how to handle for value marshalers, for example
-		if target.Type().Elem() != reflect.TypeFor[Node]() && target.Type().Elem() != reflect.TypeFor[*Node]() {
-			return fmt.Errorf("children field slice must be []kdl.Node or []*kdl.Node, got %s", target.Type())
-		}
+		// a []Node or []*Node receives the raw nodes; any other element type
+		// is unmarshaled from each node in turn, discarding the node names
 		slice := reflect.MakeSlice(target.Type(), len(children), len(children))
 		for i, child := range children {
 			elem := slice.Index(i)
@@ -447,6 +461,12 @@ func (d *decoder) unmarshalChildrenField(children []*Node, tag structTag, target
 	}
 }
 
+// unknownNodeError returns an error for a node that does not map to any struct
+// field, identifying it by location and path.
+func unknownNodeError(node *Node) error {
+	return fmt.Errorf("%s: %w %q", node.loc, ErrUnknownNode, node.Path())
+}
+
 // unwrapPointer unwraps a reflect.Value pointer, allocating a new value if the
 // pointer is nil. If the reflect.Value is not a pointer, it is returned
 // unchanged.
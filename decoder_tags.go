@@ -164,9 +164,10 @@ func parseStructTag(str string) (t structTag, err error) {
 
 type structContext struct {
 	tags           []structTag
-	argsField      int // required
-	propsField     int // required
-	childrenField  int // required
+	fields         []structField // parallel to tags
+	argsField      int           // required
+	propsField     int           // required
+	childrenField  int           // required
 	argFields      []int
 	unusedFields   map[int]struct{}    // required
 	strictFields   map[int]struct{}    // required
@@ -174,9 +175,24 @@ type structContext struct {
 	usedProperties map[string]struct{} // required
 }
 
+// structField locates a field of a struct, or a field promoted from a struct
+// embedded in it.
+type structField struct {
+	name       string // Go field name
+	index      []int  // as in [reflect.Type.FieldByIndex]
+	unexported bool
+	tagged     bool // has a kdl tag
+}
+
+// newStructContext parses the KDL tags of typ's fields. The fields of embedded
+// structs without a kdl tag are promoted into typ, as with encoding/json: they
+// are matched as if they were declared in typ itself. If a promoted field has
+// the same name as a field less deeply embedded (a field of typ itself, for
+// example), the outer field wins and the promoted one is ignored; the same
+// goes for arguments, properties, and children fields. Conflicts between
+// equally deep fields are settled by their kdl tags, as with encoding/json.
 func newStructContext(typ reflect.Type) (*structContext, error) {
 	ctx := &structContext{
-		tags:           make([]structTag, typ.NumField()),
 		argsField:      -1,
 		propsField:     -1,
 		childrenField:  -1,
@@ -186,29 +202,17 @@ func newStructContext(typ reflect.Type) (*structContext, error) {
 		usedChildren:   make(map[int]struct{}),
 		usedProperties: make(map[string]struct{}),
 	}
-	for fieldIndex := 0; fieldIndex < typ.NumField(); fieldIndex++ {
-		field := typ.Field(fieldIndex)
-		tagStr, hasKdlTag := field.Tag.Lookup("kdl")
-		if !hasKdlTag {
-			tagStr = field.Name
-		}
-
-		if !field.IsExported() {
-			if hasKdlTag {
-				return nil, fmt.Errorf("unexported field %q has kdl tag", field.Name)
-			}
+	if err := ctx.addFields(typ, nil, map[reflect.Type]bool{}); err != nil {
+		return nil, err
+	}
+	ctx.dropShadowedFields()
 
-			// otherwise, ignore unexported field
-			ctx.tags[fieldIndex] = structTag{}
+	for fieldIndex, tag := range ctx.tags {
+		name := ctx.fields[fieldIndex].name
+		if ctx.fields[fieldIndex].unexported {
 			continue
 		}
 
-		tag, err := parseStructTag(tagStr)
-		if err != nil {
-			return nil, fmt.Errorf("parsing kdl tag for field %q: %w", field.Name, err)
-		}
-		ctx.tags[fieldIndex] = tag
-
 		if tag.flags&strict != 0 {
 			ctx.strictFields[fieldIndex] = struct{}{}
 		}
@@ -220,19 +224,19 @@ func newStructContext(typ reflect.Type) (*structContext, error) {
 		}
 		if tag.flags&arguments != 0 {
 			if ctx.argsField != -1 {
-				return nil, fmt.Errorf("multiple arguments fields in struct (field %q and %q in struct %s)", typ.Field(ctx.argsField).Name, field.Name, typ)
+				return nil, fmt.Errorf("multiple arguments fields in struct (field %q and %q in struct %s)", ctx.fields[ctx.argsField].name, name, typ)
 			}
 			ctx.argsField = fieldIndex
 		}
 		if tag.flags&properties != 0 {
 			if ctx.propsField != -1 {
-				return nil, fmt.Errorf("multiple properties fields in struct (field %q and %q in struct %s)", typ.Field(ctx.propsField).Name, field.Name, typ)
+				return nil, fmt.Errorf("multiple properties fields in struct (field %q and %q in struct %s)", ctx.fields[ctx.propsField].name, name, typ)
 			}
 			ctx.propsField = fieldIndex
 		}
 		if tag.flags&children != 0 {
 			if ctx.childrenField != -1 {
-				return nil, fmt.Errorf("multiple children fields in struct (field %q and %q in struct %s)", typ.Field(ctx.childrenField).Name, field.Name, typ)
+				return nil, fmt.Errorf("multiple children fields in struct (field %q and %q in struct %s)", ctx.fields[ctx.childrenField].name, name, typ)
 			}
 			ctx.childrenField = fieldIndex
 		}
@@ -241,6 +245,173 @@ func newStructContext(typ reflect.Type) (*structContext, error) {
 	return ctx, nil
 }
 
+// addFields appends the fields of typ, found at index within the outermost
+// struct, to ctx.tags and ctx.fields, recursing into embedded structs. visiting
+// holds the embedded struct types being expanded, to stop at cycles.
+func (ctx *structContext) addFields(typ reflect.Type, index []int, visiting map[reflect.Type]bool) error {
+	visiting[typ] = true
+	defer delete(visiting, typ)
+
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		fieldIndex := append(slices.Clone(index), i)
+		tagStr, hasKdlTag := field.Tag.Lookup("kdl")
+
+		if embedded := promotedStruct(field); embedded != nil && !hasKdlTag {
+			if visiting[embedded] {
+				continue
+			}
+			if err := ctx.addFields(embedded, fieldIndex, visiting); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !hasKdlTag {
+			tagStr = field.Name
+		}
+
+		var tag structTag
+		if !field.IsExported() {
+			if hasKdlTag {
+				return fmt.Errorf("unexported field %q has kdl tag", field.Name)
+			}
+			// otherwise, ignore unexported field
+		} else {
+			var err error
+			tag, err = parseStructTag(tagStr)
+			if err != nil {
+				return fmt.Errorf("parsing kdl tag for field %q: %w", field.Name, err)
+			}
+		}
+		ctx.tags = append(ctx.tags, tag)
+		ctx.fields = append(ctx.fields, structField{name: field.Name, index: fieldIndex, unexported: !field.IsExported(), tagged: hasKdlTag})
+	}
+	return nil
+}
+
+// promotedStruct returns the struct type whose fields an embedded field
+// promotes, or nil if field is not embedded or is not promoted. Structs the
+// decoder and encoder handle as a whole, such as time.Time and types with
+// their own (un)marshaling methods, are not promoted.
+func promotedStruct(field reflect.StructField) reflect.Type {
+	if !field.Anonymous {
+		return nil
+	}
+	typ := field.Type
+	if typ.Kind() == reflect.Pointer {
+		if !field.IsExported() {
+			// can't allocate through an unexported pointer
+			return nil
+		}
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	switch typ {
+	case timeType, bigIntType, bigFloatType, reflect.TypeFor[Node](), reflect.TypeFor[Value]():
+		return nil
+	}
+	ptr := reflect.PointerTo(typ)
+	for _, iface := range []reflect.Type{
+		reflect.TypeFor[Marshaler](),
+		reflect.TypeFor[ValueMarshaler](),
+		reflect.TypeFor[DocumentMarshaler](),
+		reflect.TypeFor[Unmarshaler](),
+		reflect.TypeFor[ValueUnmarshaler](),
+		reflect.TypeFor[DocumentUnmarshaler](),
+	} {
+		if ptr.Implements(iface) {
+			return nil
+		}
+	}
+	return typ
+}
+
+// dropShadowedFields removes fields which conflict with another field with the
+// same name, or, for unnamed fields consuming all remaining arguments,
+// properties, or children, the same flag. As with encoding/json, the least
+// deeply embedded of the conflicting fields wins; if several promoted fields
+// are equally deep, the one with a kdl tag wins, and if there is no single such
+// field, all of them are dropped. Conflicting fields declared in the outermost
+// struct itself are all kept; newStructContext reports those that consume all
+// remaining arguments, properties, or children.
+func (ctx *structContext) dropShadowedFields() {
+	shadowKey := func(tag structTag) string {
+		switch {
+		case tag.name == "" || tag.name == "-":
+			return "," + (tag.flags & (arguments | properties | children)).String()
+		default:
+			return tag.name
+		}
+	}
+
+	// candidates holds, for each key, the fields at the least depth seen
+	candidates := map[string][]int{}
+	for i, tag := range ctx.tags {
+		key := shadowKey(tag)
+		if key == "," {
+			continue
+		}
+		c := candidates[key]
+		switch {
+		case len(c) == 0 || len(ctx.fields[i].index) < len(ctx.fields[c[0]].index):
+			candidates[key] = []int{i}
+		case len(ctx.fields[i].index) == len(ctx.fields[c[0]].index):
+			candidates[key] = append(c, i)
+		}
+	}
+	keep := map[int]bool{}
+	for _, c := range candidates {
+		if len(c) > 1 && len(ctx.fields[c[0]].index) > 1 {
+			c = slices.DeleteFunc(c, func(i int) bool { return !ctx.fields[i].tagged })
+			if len(c) != 1 {
+				continue
+			}
+		}
+		for _, i := range c {
+			keep[i] = true
+		}
+	}
+
+	tags, fields := ctx.tags[:0], ctx.fields[:0]
+	for i, tag := range ctx.tags {
+		if shadowKey(tag) != "," && !keep[i] {
+			continue
+		}
+		tags, fields = append(tags, tag), append(fields, ctx.fields[i])
+	}
+	ctx.tags, ctx.fields = tags, fields
+}
+
+// field returns the i-th field of target, a value of the struct type ctx was
+// created for, allocating any nil embedded struct pointers along the way.
+func (ctx *structContext) field(target reflect.Value, i int) reflect.Value {
+	for j, x := range ctx.fields[i].index {
+		if j > 0 {
+			target = unwrapPointer(target)
+		}
+		target = target.Field(x)
+	}
+	return target
+}
+
+// fieldIfPresent is like field, but reports false instead of allocating if an
+// embedded struct pointer along the way is nil.
+func (ctx *structContext) fieldIfPresent(target reflect.Value, i int) (reflect.Value, bool) {
+	for j, x := range ctx.fields[i].index {
+		if j > 0 && target.Kind() == reflect.Pointer {
+			if target.IsNil() {
+				return reflect.Value{}, false
+			}
+			target = target.Elem()
+		}
+		target = target.Field(x)
+	}
+	return target, true
+}
+
 func (ctx *structContext) markFieldUsed(index int) {
 	delete(ctx.unusedFields, index)
 	delete(ctx.strictFields, index)
@@ -1,6 +1,7 @@
 package kdl_test
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -395,6 +396,223 @@ func TestDecodeStructTags(t *testing.T) {
 	}
 }
 
+type CommonFields struct {
+	ID      string `kdl:"id,prop"`
+	Enabled bool   `kdl:"enabled,prop"`
+	Name    string `kdl:"name,prop"`
+}
+
+type Labels struct {
+	Labels map[string]string `kdl:",props"`
+}
+
+func TestDecodeEmbeddedStruct(t *testing.T) {
+	type Service struct {
+		CommonFields
+		*Labels
+		Name string `kdl:"name,prop"` // shadows CommonFields.Name
+		Port int    `kdl:"port"`
+	}
+
+	type D struct {
+		Service []Service `kdl:"service,multiple"`
+	}
+
+	doc := `
+		service id=api enabled=#true name=API team=core {
+			port 8080
+		}
+		service id=db name=DB {
+			port 5432
+		}
+	`
+
+	expected := D{
+		Service: []Service{
+			{
+				CommonFields: CommonFields{ID: "api", Enabled: true},
+				Labels:       &Labels{Labels: map[string]string{"team": "core"}},
+				Name:         "API",
+				Port:         8080,
+			},
+			{
+				CommonFields: CommonFields{ID: "db"},
+				Labels:       &Labels{Labels: map[string]string{}},
+				Name:         "DB",
+				Port:         5432,
+			},
+		},
+	}
+
+	var actual D
+	if err := kdl.Decode(strings.NewReader(doc), &actual); err != nil {
+		t.Fatalf("Decode failed: %+v", err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Value mismatch\nExpected:\n%s\nGot:\n%s", spew.Sdump(expected), spew.Sdump(actual))
+	}
+
+	out, err := kdl.Marshal(D{Service: actual.Service[1:]})
+	if err != nil {
+		t.Fatalf("Marshal failed: %+v", err)
+	}
+	if got, want := out.String(), "service id=db enabled=#false name=DB {\n    port 5432\n}\n"; got != want {
+		t.Errorf("Marshal mismatch\nExpected:\n%s\nGot:\n%s", want, got)
+	}
+}
+
+func TestDecodeEmbeddedStructConflicts(t *testing.T) {
+	type Left struct {
+		Name  string `kdl:"name,prop"`
+		Count int    `kdl:"Count,prop"`
+	}
+	type Right struct {
+		Name  string `kdl:"name,prop"`
+		Count int    // a child named Count, shadowed by the tagged Left.Count
+	}
+	type Both struct {
+		Left
+		Right
+	}
+	type Tagged struct {
+		CommonFields `kdl:"common"` // not promoted
+		Name         string         `kdl:"name,prop"`
+	}
+	type D struct {
+		Both   Both   `kdl:"both"`
+		Tagged Tagged `kdl:"tagged"`
+	}
+
+	doc := `
+		both name=x Count=3 {
+			Count 4
+		}
+		tagged name=outer {
+			common id=inner name=common
+		}
+	`
+	expected := D{
+		Both:   Both{Left: Left{Count: 3}},
+		Tagged: Tagged{CommonFields: CommonFields{ID: "inner", Name: "common"}, Name: "outer"},
+	}
+
+	var actual D
+	if err := kdl.Decode(strings.NewReader(doc), &actual); err != nil {
+		t.Fatalf("Decode failed: %+v", err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Value mismatch\nExpected:\n%s\nGot:\n%s", spew.Sdump(expected), spew.Sdump(actual))
+	}
+}
+
+func TestDecodeRemainingChildren(t *testing.T) {
+	type Plugin struct {
+		Path    string `kdl:",arg"`
+		Enabled bool   `kdl:"enabled,prop"`
+	}
+
+	type Config struct {
+		Name    string   `kdl:"name"`
+		Plugins []Plugin `kdl:",children"`
+	}
+
+	type RawConfig struct {
+		Name  string      `kdl:"name"`
+		Extra []*kdl.Node `kdl:",children"`
+	}
+
+	doc := `
+		config {
+			name app
+			lint "./lint.so" enabled=#true
+			fmt "./fmt.so"
+		}
+	`
+
+	var typed struct {
+		Config Config `kdl:"config"`
+	}
+	if err := kdl.Decode(strings.NewReader(doc), &typed); err != nil {
+		t.Fatalf("Decode failed: %+v", err)
+	}
+	expected := Config{
+		Name:    "app",
+		Plugins: []Plugin{{Path: "./lint.so", Enabled: true}, {Path: "./fmt.so"}},
+	}
+	if !reflect.DeepEqual(expected, typed.Config) {
+		t.Errorf("Value mismatch\nExpected:\n%s\nGot:\n%s", spew.Sdump(expected), spew.Sdump(typed.Config))
+	}
+
+	var raw struct {
+		Config RawConfig `kdl:"config"`
+	}
+	if err := kdl.Decode(strings.NewReader(doc), &raw); err != nil {
+		t.Fatalf("Decode failed: %+v", err)
+	}
+	var names []string
+	for _, n := range raw.Config.Extra {
+		names = append(names, n.Name())
+	}
+	if got := strings.Join(names, ","); got != "lint,fmt" {
+		t.Errorf("got extra children %s, want lint,fmt", got)
+	}
+
+	var twice struct {
+		Config struct {
+			A []*kdl.Node `kdl:",children"`
+			B []Plugin    `kdl:",children"`
+		} `kdl:"config"`
+	}
+	if err := kdl.Decode(strings.NewReader(doc), &twice); err == nil {
+		t.Errorf("Expected error for two children fields, but got none")
+	}
+}
+
+func TestDecodeDisallowUnknownNodes(t *testing.T) {
+	type Server struct {
+		Host  string            `kdl:"host"`
+		Port  int               `kdl:"port"`
+		Extra map[string]string `kdl:",props"`
+	}
+	type Config struct {
+		Server Server `kdl:"server"`
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		wantErr  string
+	}{
+		{"known nodes", "server debug=yes {\n  host localhost\n  port 80\n}", ""},
+		{"unknown child", "server {\n  host localhost\n  prot 80\n}", `3:3: unknown node "server/prot"`},
+		{"unknown top-level node", "server\nsevrer", `2:1: unknown node "sevrer"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var lenient Config
+			if err := kdl.DecodeString(test.document, &lenient); err != nil {
+				t.Fatalf("Decode failed without option: %+v", err)
+			}
+
+			var actual Config
+			err := kdl.DecodeString(test.document, &actual, kdl.WithDisallowUnknownNodes(true))
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("Decode failed: %+v", err)
+				}
+				return
+			}
+			if !errors.Is(err, kdl.ErrUnknownNode) {
+				t.Fatalf("Expected ErrUnknownNode, got %v", err)
+			}
+			if !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Expected error containing %q, got %q", test.wantErr, err)
+			}
+		})
+	}
+}
+
 func TestDecodeStructTagErrors(t *testing.T) {
 	tags := []reflect.StructTag{
 		`kdl:",arg,arg"`,
@@ -704,3 +922,169 @@ func TestDecodeLocated(t *testing.T) {
 		t.Errorf("Document mismatch\nExpected:\n%s\nGot:\n%s", expectedDoc, buf.String())
 	}
 }
+
+// settings marshals itself as one key-value node per entry, in key order.
+type settings struct {
+	keys   []string
+	values map[string]string
+}
+
+func (s *settings) MarshalKDLDocument() (*kdl.Document, error) {
+	doc := kdl.NewDocument()
+	for _, k := range s.keys {
+		doc.AddNode(kdl.NewKV(k, s.values[k]))
+	}
+	return doc, nil
+}
+
+func (s *settings) UnmarshalKDLDocument(doc *kdl.Document) error {
+	s.values = map[string]string{}
+	for _, n := range doc.Nodes {
+		v, err := doc.GetKV(n.Name())
+		if err != nil {
+			return err
+		}
+		s.keys = append(s.keys, n.Name())
+		s.values[n.Name()] = v.String()
+	}
+	return nil
+}
+
+func TestDocumentMarshalerRoundTrip(t *testing.T) {
+	in := &settings{keys: []string{"theme", "font"}, values: map[string]string{"theme": "dark", "font": "mono"}}
+	src, err := kdl.EncodeToString(in)
+	if err != nil {
+		t.Fatalf("EncodeToString() error = %v", err)
+	}
+	if want := "theme dark\nfont mono\n"; src != want {
+		t.Errorf("EncodeToString() = %q, want %q", src, want)
+	}
+
+	var out settings
+	if err := kdl.DecodeString(src, &out); err != nil {
+		t.Fatalf("DecodeString() error = %v", err)
+	}
+	if !reflect.DeepEqual(&out, in) {
+		t.Errorf("DecodeString() = %v, want %v", out, in)
+	}
+
+	if err := kdl.DecodeString("theme dark light", &out); err == nil {
+		t.Error("DecodeString() succeeded, want the UnmarshalKDLDocument error")
+	}
+}
+
+func TestDecodeScalarSliceModes(t *testing.T) {
+	type Group struct {
+		Names []string `kdl:",args"`
+		Items []string `kdl:",children"`
+	}
+	type Config struct {
+		Tags  []string `kdl:"tags"`
+		Hosts []string `kdl:"host,multiple"`
+		Group Group    `kdl:"group"`
+	}
+
+	doc := `
+		tags a b c
+		host one
+		host two
+		group x y {
+			item first
+			item second
+		}
+	`
+	var got Config
+	if err := kdl.Decode(strings.NewReader(doc), &got); err != nil {
+		t.Fatalf("Decode failed: %+v", err)
+	}
+	expected := Config{
+		Tags:  []string{"a", "b", "c"},
+		Hosts: []string{"one", "two"},
+		Group: Group{Names: []string{"x", "y"}, Items: []string{"first", "second"}},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("Value mismatch\nExpected:\n%s\nGot:\n%s", spew.Sdump(expected), spew.Sdump(got))
+	}
+}
+
+var (
+	errBadPort     = errors.New("port out of range")
+	validatedOrder []string
+)
+
+type validatedListener struct {
+	Port int `kdl:"port"`
+}
+
+func (l *validatedListener) Validate() error {
+	validatedOrder = append(validatedOrder, "listener")
+	if l.Port < 1 || l.Port > 65535 {
+		return errBadPort
+	}
+	return nil
+}
+
+type validatedConfig struct {
+	Listeners []validatedListener `kdl:"listener,multiple"`
+}
+
+func (c validatedConfig) Validate() error {
+	validatedOrder = append(validatedOrder, "config")
+	return nil
+}
+
+func TestDecodeValidator(t *testing.T) {
+	validatedOrder = nil
+	var cfg validatedConfig
+	if err := kdl.DecodeString("listener { port 80; }\nlistener { port 443; }", &cfg); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if want := []string{"listener", "listener", "config"}; !reflect.DeepEqual(validatedOrder, want) {
+		t.Errorf("Validate call order = %v, want %v", validatedOrder, want)
+	}
+
+	validatedOrder = nil
+	cfg = validatedConfig{}
+	err := kdl.DecodeString("listener { port 80; }\nlistener { port 0; }\nlistener { port -1; }", &cfg)
+	if !errors.Is(err, errBadPort) {
+		t.Fatalf("Decode() error = %v, want %v", err, errBadPort)
+	}
+	if want := `2:1: validating node "listener": port out of range`; !strings.Contains(err.Error(), want) {
+		t.Errorf("Decode() error = %q, want it to contain %q", err, want)
+	}
+	if want := []string{"listener", "listener"}; !reflect.DeepEqual(validatedOrder, want) {
+		t.Errorf("Validate call order = %v, want %v", validatedOrder, want)
+	}
+}
+
+type childDatabase struct {
+	Host string
+	Port int
+}
+
+func (db *childDatabase) UnmarshalKDL(node *kdl.Node) error {
+	return kdl.Unmarshal(node, &struct {
+		Host *string `kdl:"host"`
+		Port *int    `kdl:"port"`
+	}{&db.Host, &db.Port})
+}
+
+func TestGetChildAs(t *testing.T) {
+	doc, err := kdl.ParseString(`app { database { host db.local; port 5432; }; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := doc.Nodes[0]
+
+	db, err := kdl.GetChildAs[childDatabase](app, "database")
+	if err != nil {
+		t.Fatalf("GetChildAs() error = %v", err)
+	}
+	if want := (childDatabase{Host: "db.local", Port: 5432}); *db != want {
+		t.Errorf("GetChildAs() = %+v, want %+v", *db, want)
+	}
+
+	if _, err := kdl.GetChildAs[childDatabase](app, "cache"); !errors.Is(err, kdl.ErrNotFound) {
+		t.Errorf("GetChildAs(missing) error = %v, want ErrNotFound", err)
+	}
+}
@@ -47,6 +47,10 @@ type ParseResult struct {
 	// Version is the KDL spec version the parser settled on. Set even when
 	// the input was originally parsed under VersionAuto.
 	Version Version
+	// SuggestedVersion is set when a version was requested with WithVersion,
+	// the input had errors at that version, and it parses cleanly at the
+	// other version. It is VersionAuto otherwise.
+	SuggestedVersion Version
 }
 
 // HasErrors returns true if any diagnostic has SeverityError.
@@ -93,6 +97,8 @@ const (
 	DiagParseVersionAutoFallback   = "kdl/parse/version-auto-fallback"
 	DiagParseVersionMarkerInvalid  = "kdl/parse/version-marker-invalid"
 	DiagParseVersionMarkerMismatch = "kdl/parse/version-marker-mismatch"
+	DiagParseVersionMismatch       = "kdl/parse/version-mismatch"
+	DiagParseMaxDepthExceeded      = "kdl/parse/max-depth-exceeded"
 
 	// schema validation
 
@@ -0,0 +1,174 @@
+package kdl
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A ChangeKind describes how an entry differs between two documents in a
+// [Change].
+type ChangeKind uint8
+
+const (
+	// ChangeAdded means the entry only exists in the new document.
+	ChangeAdded ChangeKind = iota
+	// ChangeRemoved means the entry only exists in the old document.
+	ChangeRemoved
+	// ChangeModified means the entry exists in both documents with different
+	// contents.
+	ChangeModified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", k)
+	}
+}
+
+// A Change is a single structural difference between two documents, as
+// reported by [Diff].
+//
+// Path identifies the changed entry. Nodes are identified by their names joined
+// with slashes, as in [Node.Path]; the second and later nodes with the same
+// name under the same parent have a zero-based index appended, as in
+// "server/route[1]". An argument is identified by its node's path followed by
+// "#" and its index, as in "server#0", and a property by its node's path
+// followed by "." and its key, as in "server.port". A node name or property key
+// that is empty or contains any of the characters / . # [ ] " is written as a
+// quoted KDL string, as in `"example.com"/route.port`, so that every entry has
+// a distinct path.
+//
+// For node changes, Old and New are *Node; for argument and property changes,
+// they are [Value]. Old is nil for added entries and New is nil for removed
+// entries. A modified node is one whose type annotation changed.
+type Change struct {
+	Kind ChangeKind
+	Path string
+	Old  any
+	New  any
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s", c.Kind, c.Path)
+}
+
+// Diff compares documents a and b and returns the changes that turn a into b.
+//
+// Nodes are matched by name in the same way as [Document.Merge]: the nth node
+// with a given name in a matches the nth node with that name in b. Matched
+// nodes are compared by type annotation, arguments (by position), properties
+// (by key, so reordering properties is not a change), and children,
+// recursively; values are compared with [ValuesEqual]. Comments and formatting
+// are ignored.
+//
+// The changes are deterministic: at each level, the changes for the nodes of a
+// (including the changes within matched nodes) come first in document order,
+// followed by the nodes only in b. Within a node, type annotation changes come
+// first, then arguments in order, then properties sorted by key, then children.
+// Diff returns an error if a or b is nil.
+func Diff(a, b *Document) ([]Change, error) {
+	if a == nil || b == nil {
+		return nil, errors.New("kdl.Diff: nil document")
+	}
+	var changes []Change
+	diffNodes(&changes, "", a.Nodes, b.Nodes)
+	return changes, nil
+}
+
+func diffNodes(changes *[]Change, prefix string, a, b []*Node) {
+	matched := make([]bool, len(b))
+	seen := map[string]int{}
+	for _, n := range a {
+		nth := seen[n.name]
+		seen[n.name]++
+		path := nthNodePath(prefix, n.name, nth)
+
+		i := nthNodeNamed(b, n.name, nth)
+		if i < 0 {
+			*changes = append(*changes, Change{Kind: ChangeRemoved, Path: path, Old: n})
+			continue
+		}
+		matched[i] = true
+		diffNode(changes, path, n, b[i])
+	}
+
+	seen = map[string]int{}
+	for i, n := range b {
+		nth := seen[n.name]
+		seen[n.name]++
+		if matched[i] {
+			continue
+		}
+		*changes = append(*changes, Change{Kind: ChangeAdded, Path: nthNodePath(prefix, n.name, nth), New: n})
+	}
+}
+
+// nthNodePath returns the path of the nth (zero-based) node with the given
+// name under prefix, as described in [Change].
+func nthNodePath(prefix, name string, nth int) string {
+	if nth > 0 {
+		return prefix + pathSegment(name) + "[" + strconv.Itoa(nth) + "]"
+	}
+	return prefix + pathSegment(name)
+}
+
+// pathSegment returns the node name or property key s as it is written in a
+// path: as is, or as a quoted string if it would otherwise be ambiguous.
+func pathSegment(s string) string {
+	if s == "" || strings.ContainsAny(s, `/.#[]"`) {
+		return `"` + EscapeString(s, Version2) + `"`
+	}
+	return s
+}
+
+func diffNode(changes *[]Change, path string, a, b *Node) {
+	if a.typeValid != b.typeValid || a.typ != b.typ {
+		*changes = append(*changes, Change{Kind: ChangeModified, Path: path, Old: a, New: b})
+	}
+
+	for i := range max(len(a.args), len(b.args)) {
+		argPath := path + "#" + strconv.Itoa(i)
+		switch {
+		case i >= len(b.args):
+			*changes = append(*changes, Change{Kind: ChangeRemoved, Path: argPath, Old: a.args[i]})
+		case i >= len(a.args):
+			*changes = append(*changes, Change{Kind: ChangeAdded, Path: argPath, New: b.args[i]})
+		case !ValuesEqual(a.args[i], b.args[i]):
+			*changes = append(*changes, Change{Kind: ChangeModified, Path: argPath, Old: a.args[i], New: b.args[i]})
+		}
+	}
+
+	keys := slices.Collect(maps.Keys(a.props))
+	for k := range b.props {
+		if _, ok := a.props[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		propPath := path + "." + pathSegment(k)
+		av, inA := a.props[k]
+		bv, inB := b.props[k]
+		switch {
+		case !inB:
+			*changes = append(*changes, Change{Kind: ChangeRemoved, Path: propPath, Old: av})
+		case !inA:
+			*changes = append(*changes, Change{Kind: ChangeAdded, Path: propPath, New: bv})
+		case !ValuesEqual(av, bv):
+			*changes = append(*changes, Change{Kind: ChangeModified, Path: propPath, Old: av, New: bv})
+		}
+	}
+
+	diffNodes(changes, path+"/", a.children.Nodes, b.children.Nodes)
+}
@@ -0,0 +1,91 @@
+package kdl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a, err := ParseString(`server main port=80 host=localhost {
+    tls enabled=#false
+    log level=info
+}
+route "/"
+route "/api"
+(old)kind
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseString(`server main backup host=localhost port=8080 {
+    tls enabled=#false
+    timeout 30
+}
+route "/"
+route "/v2" auth=#true
+route "/extra"
+(new)kind
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Diff(a, b)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	var got []string
+	for _, c := range changes {
+		s := c.String()
+		if old, ok := c.Old.(Value); ok {
+			s += " old=" + old.String()
+		}
+		if v, ok := c.New.(Value); ok {
+			s += " new=" + v.String()
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"added server#1 new=backup",
+		"modified server.port old=<kdl.Int 80> new=<kdl.Int 8080>",
+		"removed server/log",
+		"added server/timeout",
+		"modified route[1]#0 old=/api new=/v2",
+		"added route[1].auth new=<kdl.Bool true>",
+		"modified kind",
+		"added route[2]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Diff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if n, ok := changes[2].Old.(*Node); !ok || n.Name() != "log" || changes[2].New != nil {
+		t.Errorf("removed node change = %+v, want Old = log node, New = nil", changes[2])
+	}
+
+	// names and keys that would be ambiguous are quoted
+	odd, err := ParseString(`"a/b" "x.y"=1 { "" "#"=2; }` + "\n" + `"a/b"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = got[:0]
+	changes, _ = Diff(NewDocument(), odd)
+	for _, c := range changes {
+		got = append(got, c.Path)
+	}
+	changes, _ = Diff(odd, NewDocument(NewNode("a/b", NewInt(1)).AddChild(NewNode(""))))
+	for _, c := range changes {
+		got = append(got, c.Path)
+	}
+	want = []string{`"a/b"`, `"a/b"[1]`, `"a/b"#0`, `"a/b"."x.y"`, `"a/b"/""."#"`, `"a/b"[1]`}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Diff() paths = %q, want %q", got, want)
+	}
+
+	if changes, err := Diff(a, a.Clone()); err != nil || len(changes) != 0 {
+		t.Errorf("Diff(a, clone) = %v, %v; want no changes", changes, err)
+	}
+	if _, err := Diff(a, nil); err == nil {
+		t.Error("Diff(a, nil) succeeded, want an error")
+	}
+}
@@ -1,6 +1,17 @@
 package kdl
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned (usually wrapped with more context) when a requested
+// node, argument, or property does not exist. It can be used with [errors.Is].
+var ErrNotFound = errors.New("not found")
 
 // A Document is a collection of nodes.
 type Document struct {
@@ -8,25 +19,123 @@ type Document struct {
 	// TrailingComments holds comments that appear after the last node in the
 	// document (or children block).
 	TrailingComments []Comment
+
+	// owner is the node whose children this document holds, or nil for a
+	// top-level document.
+	owner *Node
 }
 
-// NewDocument creates a new KDL document with the given nodes.
+// NewDocument creates a new KDL document with the given nodes, which become
+// top-level nodes with no parent, as with [Document.AddNodes].
 func NewDocument(nodes ...*Node) *Document {
-	return &Document{Nodes: nodes}
+	d := &Document{}
+	d.AddNodes(nodes...)
+	return d
 }
 
 // AddNode adds a node to the document and returns the document.
 func (d *Document) AddNode(node *Node) *Document {
 	d.Nodes = append(d.Nodes, node)
+	d.adopt(node)
 	return d
 }
 
 // AddNodes adds multiple nodes to the document and returns the document.
 func (d *Document) AddNodes(nodes ...*Node) *Document {
 	d.Nodes = append(d.Nodes, nodes...)
+	d.adopt(nodes...)
 	return d
 }
 
+// adopt sets the parent of each node to the owner of d.
+func (d *Document) adopt(nodes ...*Node) {
+	for _, n := range nodes {
+		n.parent = d.owner
+	}
+}
+
+// String returns the document as KDL version 2 text, as emitted by [Emit] with
+// default options. If the document cannot be emitted (for example, because it
+// contains an invalid [Value]), String returns a debug representation in the
+// format "<kdl.Document: %v>", where %v is the error.
+func (d *Document) String() string {
+	s, err := EmitToString(d)
+	if err != nil {
+		return fmt.Sprintf("<kdl.Document: %v>", err)
+	}
+	return s
+}
+
+// WriteTo writes the document to w as KDL version 2 text, as emitted by [Emit]
+// with default options, and returns the number of bytes written. It implements
+// [io.WriterTo]; use [Emit] directly to choose a different version or other
+// options.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := Emit(d, cw)
+	return cw.n, err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// InsertNode inserts a node into the document at the given index, shifting the
+// nodes at and after it. An index equal to the number of nodes appends the
+// node. InsertNode returns an error if the index is out of range.
+func (d *Document) InsertNode(index int, node *Node) error {
+	if index < 0 || index > len(d.Nodes) {
+		return fmt.Errorf("kdl: insert index %d out of range [0, %d]", index, len(d.Nodes))
+	}
+	d.Nodes = slices.Insert(d.Nodes, index, node)
+	d.adopt(node)
+	return nil
+}
+
+// Clone creates a deep copy of the document and all of its nodes and returns
+// it. See [Node.Clone].
+func (d *Document) Clone() *Document {
+	clone := &Document{Nodes: make([]*Node, len(d.Nodes))}
+	for i, n := range d.Nodes {
+		clone.Nodes[i] = n.Clone()
+	}
+	if len(d.TrailingComments) > 0 {
+		clone.TrailingComments = make([]Comment, len(d.TrailingComments))
+		copy(clone.TrailingComments, d.TrailingComments)
+	}
+	return clone
+}
+
+// Equal reports whether d and other contain structurally equal nodes in the
+// same order. See [Node.Equal] for details; trailing comments are not
+// compared.
+func (d *Document) Equal(other *Document) bool {
+	return d.equal(other, false)
+}
+
+// EqualOrdered is like [Document.Equal], but compares nodes with
+// [Node.EqualOrdered].
+func (d *Document) EqualOrdered(other *Document) bool {
+	return d.equal(other, true)
+}
+
+func (d *Document) equal(other *Document, ordered bool) bool {
+	if d == nil || other == nil {
+		return d == other
+	}
+	return slices.EqualFunc(d.Nodes, other.Nodes, func(a, b *Node) bool {
+		return a.equal(b, ordered)
+	})
+}
+
 // GetNode gets the first node with the given name from the KDL document and
 // returns it.
 //
@@ -41,6 +150,75 @@ func (d *Document) GetNode(name string) *Node {
 	return nil
 }
 
+// At looks up a node by a slash-separated path of node names, such as
+// "server/database/host". Each segment selects the first node with that name
+// among the children of the node selected by the previous segment, starting
+// from the top-level nodes of d.
+//
+// If any segment does not match, At returns an error wrapping [ErrNotFound]
+// that names the missing segment.
+func (d *Document) At(path string) (*Node, error) {
+	return lookupPath(d, path)
+}
+
+func lookupPath(d *Document, path string) (*Node, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: empty path", ErrNotFound)
+	}
+	var n *Node
+	for segment := range strings.SplitSeq(path, "/") {
+		n = d.GetNode(segment)
+		if n == nil {
+			return nil, fmt.Errorf("%w: no node %q (looking up %q)", ErrNotFound, segment, path)
+		}
+		d = &n.children
+	}
+	return n, nil
+}
+
+// Value looks up an argument or property value by path. The path consists of
+// a node path, as accepted by [Document.At], followed by a slash and a final
+// segment naming a value of the node it selects:
+//
+//	path    = nodes "/" value
+//	nodes   = name *("/" name)
+//	value   = index / key
+//	index   = 1*DIGIT  ; zero-based argument index
+//	key     = name     ; property key
+//
+// For example, "server/host" is the host property of the first server node,
+// and "server/ports/0" is the first argument of its first ports child. A final
+// segment made up only of ASCII digits is always an argument index, so
+// properties with such keys cannot be addressed.
+//
+// If any node along the path or the final argument or property does not
+// exist, Value returns an error wrapping [ErrNotFound] that names the missing
+// segment.
+func (d *Document) Value(path string) (Value, error) {
+	i := strings.LastIndexByte(path, '/')
+	if i < 0 {
+		return Value{}, fmt.Errorf("%w: path %q has no value segment", ErrNotFound, path)
+	}
+	n, err := lookupPath(d, path[:i])
+	if err != nil {
+		return Value{}, err
+	}
+
+	segment := path[i+1:]
+	if segment != "" && strings.Trim(segment, "0123456789") == "" {
+		index, err := strconv.Atoi(segment)
+		if err != nil || index >= len(n.args) {
+			return Value{}, fmt.Errorf("%w: node %s has no argument %s (looking up %q)", ErrNotFound, n.name, segment, path)
+		}
+		return n.args[index], nil
+	}
+	v, ok := n.props[segment]
+	if !ok {
+		return Value{}, fmt.Errorf("%w: node %s has no property %q (looking up %q)", ErrNotFound, n.name, segment, path)
+	}
+	return v, nil
+}
+
 // GetNodes gets all nodes with the given name from the KDL document and returns
 // them.
 //
@@ -59,14 +237,14 @@ func (d *Document) GetNodes(name string) []*Node {
 // returns its first argument.
 //
 // If no such node exists, GetKV returns a zero Value and a nil error. If the
-// node does not have exactly one argument, a non-nil error is returned.
+// node has no arguments, the returned error wraps [ErrNotFound]; if it has more
+// than one argument, a non-nil error is returned as well. Unlike
+// [Node.GetKVs], which silently skips children without exactly one argument,
+// GetKV reports these cases as errors.
 func (d *Document) GetKV(name string) (Value, error) {
 	for _, child := range d.Nodes {
 		if child.name == name {
-			if len(child.args) != 1 {
-				return Value{}, fmt.Errorf("node %s does not have exactly one argument", name)
-			}
-			return child.Arg(0), nil
+			return child.Single()
 		}
 	}
 
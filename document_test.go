@@ -0,0 +1,282 @@
+package kdl_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/calico32/kdl-go"
+)
+
+func TestDocumentGetKV(t *testing.T) {
+	doc, err := kdl.ParseString("user\nhost example.com\nports 80 443\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	v, err := doc.GetKV("host")
+	if err != nil {
+		t.Fatalf("GetKV(host) error = %v", err)
+	}
+	if v.String() != "example.com" {
+		t.Errorf("GetKV(host) = %v, want example.com", v)
+	}
+
+	_, err = doc.GetKV("user")
+	if !errors.Is(err, kdl.ErrNotFound) {
+		t.Errorf("GetKV(user) error = %v, want ErrNotFound", err)
+	}
+
+	_, err = doc.GetKV("ports")
+	if err == nil || errors.Is(err, kdl.ErrNotFound) {
+		t.Errorf("GetKV(ports) error = %v, want non-ErrNotFound error", err)
+	}
+
+	v, err = doc.GetKV("missing")
+	if err != nil || v.IsValid() {
+		t.Errorf("GetKV(missing) = %v, %v; want zero Value, nil", v, err)
+	}
+}
+
+func TestDocumentClone(t *testing.T) {
+	doc, err := kdl.ParseString("// leading\nparent 1 key=big {\n    child 123456789012345678901234567890\n}\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want, err := kdl.EmitToString(doc)
+	if err != nil {
+		t.Fatalf("emit: %v", err)
+	}
+
+	clone := doc.Clone()
+	if got, _ := kdl.EmitToString(clone); got != want {
+		t.Errorf("clone emits %q, want %q", got, want)
+	}
+
+	parent := clone.Nodes[0]
+	parent.SetArg(0, kdl.NewInt(2))
+	parent.SetProp("key", kdl.NewString("small"))
+	parent.AddProperty("extra", kdl.NewBool(true))
+	parent.Children().Nodes[0].SetArg(0, kdl.NewInt(0))
+	parent.AddChild(kdl.NewNode("another"))
+	clone.AddNode(kdl.NewNode("top"))
+
+	if got, _ := kdl.EmitToString(doc); got != want {
+		t.Errorf("original changed after mutating clone: got %q, want %q", got, want)
+	}
+	if n := len(doc.Nodes[0].LeadingComments()); n != 1 {
+		t.Errorf("original has %d leading comments, want 1", n)
+	}
+}
+
+func TestDocumentEqual(t *testing.T) {
+	parse := func(src string) *kdl.Document {
+		t.Helper()
+		doc, err := kdl.ParseString(src)
+		if err != nil {
+			t.Fatalf("parse %q: %v", src, err)
+		}
+		return doc
+	}
+
+	tests := []struct {
+		name    string
+		a, b    string
+		equal   bool
+		ordered bool
+	}{
+		{"identical", "a 1 k=v { b }", "a 1 k=v { b }", true, true},
+		{"comments and layout ignored", "// hi\na 1 k=v {\n    b\n}", "a 1 k=v { b; }", true, true},
+		{"literal spelling ignored", "a 0x10 \"s\"", "a 16 s", true, true},
+		{"property order", "a x=1 y=2", "a y=2 x=1", true, false},
+		{"prop and arg interleaving", "a x=1 2", "a 2 x=1", true, true},
+		{"different name", "a", "b", false, false},
+		{"different type annotation", "(t)a", "a", false, false},
+		{"different arg", "a 1", "a 2", false, false},
+		{"different arg type annotation", "a (u8)1", "a 1", false, false},
+		{"different arg count", "a 1", "a 1 1", false, false},
+		{"different prop", "a x=1", "a x=2", false, false},
+		{"missing prop", "a x=1", "a", false, false},
+		{"different children", "a { b }", "a { c }", false, false},
+		{"child order", "a { b; c }", "a { c; b }", false, false},
+		{"nested property order", "a { b x=1 y=2 }", "a { b y=2 x=1 }", true, false},
+		{"different node count", "a; b", "a", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := parse(tt.a), parse(tt.b)
+			if got := a.Equal(b); got != tt.equal {
+				t.Errorf("Equal() = %v, want %v", got, tt.equal)
+			}
+			if got := b.Equal(a); got != tt.equal {
+				t.Errorf("Equal() (swapped) = %v, want %v", got, tt.equal)
+			}
+			if got := a.EqualOrdered(b); got != tt.ordered {
+				t.Errorf("EqualOrdered() = %v, want %v", got, tt.ordered)
+			}
+		})
+	}
+
+	doc := parse("a 1 { b }")
+	if !doc.Equal(doc.Clone()) {
+		t.Error("document not equal to its clone")
+	}
+	var nilNode *kdl.Node
+	if doc.Nodes[0].Equal(nil) || !nilNode.Equal(nil) {
+		t.Error("unexpected nil node comparison result")
+	}
+}
+
+func TestDocumentAt(t *testing.T) {
+	doc, err := kdl.ParseString("server {\n    database {\n        host db.local\n    }\n    database {\n        host other\n    }\n}\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	host, err := doc.At("server/database/host")
+	if err != nil {
+		t.Fatalf("At() error = %v", err)
+	}
+	if got := host.Arg(0).String(); got != "db.local" {
+		t.Errorf("At() = %q, want first match db.local", got)
+	}
+
+	db, err := doc.At("server/database")
+	if err != nil {
+		t.Fatalf("At() error = %v", err)
+	}
+	if rel, err := db.At("host"); err != nil || rel != host {
+		t.Errorf("Node.At() = %v, %v, want the same host node", rel, err)
+	}
+
+	for _, path := range []string{"server/cache/host", "missing", "", "server//host"} {
+		_, err := doc.At(path)
+		if !errors.Is(err, kdl.ErrNotFound) {
+			t.Errorf("At(%q) error = %v, want ErrNotFound", path, err)
+		}
+	}
+	if _, err := doc.At("server/cache/host"); err == nil || !strings.Contains(err.Error(), `"cache"`) {
+		t.Errorf("At() error = %v, want it to name the missing segment", err)
+	}
+}
+
+func TestDocumentValue(t *testing.T) {
+	doc, err := kdl.ParseString("server host=example.com {\n    ports 80 443\n}\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"server/host", "example.com"},
+		{"server/ports/0", "80"},
+		{"server/ports/1", "443"},
+	}
+	for _, tt := range tests {
+		v, err := doc.Value(tt.path)
+		if err != nil {
+			t.Errorf("Value(%q) error = %v", tt.path, err)
+			continue
+		}
+		if got := fmt.Sprint(v.RawValue()); got != tt.want {
+			t.Errorf("Value(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+
+	for _, path := range []string{"server", "", "server/port", "server/ports/2", "server/ports/99999999999999999999", "client/host", "server/ports/"} {
+		if _, err := doc.Value(path); !errors.Is(err, kdl.ErrNotFound) {
+			t.Errorf("Value(%q) error = %v, want ErrNotFound", path, err)
+		}
+	}
+}
+
+func TestDocumentInsertNode(t *testing.T) {
+	doc := kdl.NewDocument(kdl.NewNode("b"), kdl.NewNode("d"))
+	for _, step := range []struct {
+		index int
+		name  string
+	}{{0, "a"}, {2, "c"}, {4, "e"}} {
+		if err := doc.InsertNode(step.index, kdl.NewNode(step.name)); err != nil {
+			t.Fatalf("InsertNode(%d, %s) error = %v", step.index, step.name, err)
+		}
+	}
+	for _, index := range []int{-1, 6} {
+		if err := doc.InsertNode(index, kdl.NewNode("x")); err == nil {
+			t.Errorf("InsertNode(%d) succeeded, want an error", index)
+		}
+	}
+
+	parent := kdl.NewNode("parent").AddChild(kdl.NewNode("second"))
+	if err := parent.InsertChild(0, kdl.NewNode("first")); err != nil {
+		t.Fatalf("InsertChild(0) error = %v", err)
+	}
+	if err := parent.InsertChild(3, kdl.NewNode("x")); err == nil {
+		t.Error("InsertChild(3) succeeded, want an error")
+	}
+	doc.AddNode(parent)
+
+	got, err := kdl.EmitToString(doc)
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if want := "a\nb\nc\nd\ne\nparent {\n    first\n    second\n}\n"; got != want {
+		t.Errorf("Emit() = %q, want %q", got, want)
+	}
+}
+
+func TestDocumentString(t *testing.T) {
+	doc, err := kdl.ParseString(`server "main" port=80 { child; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "server main port=80 {\n    child\n}\n"
+	if got := doc.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := fmt.Sprint(doc); got != want {
+		t.Errorf("fmt.Sprint() = %q, want %q", got, want)
+	}
+
+	bad := kdl.NewDocument(kdl.NewNode("n").AddArgument(kdl.Value{}))
+	if got := bad.String(); !strings.HasPrefix(got, "<kdl.Document: ") {
+		t.Errorf("String() = %q, want a debug representation", got)
+	}
+}
+
+func TestDocumentWriteTo(t *testing.T) {
+	doc, err := kdl.ParseString(`server "main" port=80 { child; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var _ io.WriterTo = doc
+
+	rec := httptest.NewRecorder()
+	n, err := doc.WriteTo(rec)
+	if err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	const want = "server main port=80 {\n    child\n}\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("WriteTo() wrote %q, want %q", got, want)
+	}
+	if n != int64(len(want)) {
+		t.Errorf("WriteTo() = %d, want %d", n, len(want))
+	}
+
+	bad := kdl.NewDocument(kdl.NewNode("n").AddArgument(kdl.Value{}))
+	var buf bytes.Buffer
+	n, err = bad.WriteTo(&buf)
+	if err == nil {
+		t.Error("WriteTo() of invalid document error = nil")
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo() = %d after writing %d bytes", n, buf.Len())
+	}
+}
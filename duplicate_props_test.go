@@ -208,3 +208,21 @@ func TestDuplicateProps_SetUpdatesLastInPlace(t *testing.T) {
 		t.Errorf("Properties()[a] = %v, want 99", n.Properties()["a"])
 	}
 }
+
+func TestDuplicateProps_DupErrorParse(t *testing.T) {
+	_, err := ParseString("server host=a port=1 port=2\n", WithDuplicateProperties(DupError))
+	if err == nil {
+		t.Fatal("want error with DupError")
+	}
+	if msg := err.Error(); !strings.Contains(msg, `"port"`) || !strings.Contains(msg, `"server"`) {
+		t.Errorf("error %q does not name the node and key", msg)
+	}
+
+	doc, err := ParseString("server port=1 port=2\n")
+	if err != nil {
+		t.Fatalf("unexpected error with default mode: %v", err)
+	}
+	if v := doc.Nodes[0].Prop("port").Int(); v != 2 {
+		t.Errorf("port = %d, want 2 (last wins)", v)
+	}
+}
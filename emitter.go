@@ -1,6 +1,7 @@
 package kdl
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"math"
@@ -8,23 +9,35 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Emit writes the KDL representation of the given Document to the provided
 // writer.
 //
-// Emit produces canonical, minimal, deterministic output: properties are sorted
-// alphabetically, source layout (comments, blank lines, original
-// argument/property interleaving) is not preserved, and identical [Document]
-// values always produce identical bytes. Use Emit when you want stable output
-// for storage, transmission, hashing, or diffs. For human-readable
-// pretty-printing that preserves source layout, comments, and other
-// non-semantic details, use [Format] instead.
+// Emit produces minimal, deterministic output: properties are emitted in
+// insertion order after all arguments, source layout (comments, blank lines,
+// original argument/property interleaving) is not preserved (though comments
+// set with [Node.SetComment], [Node.SetTrailingComment], and
+// [Node.SetArgumentComment] are written, except with [WithCanonical]), and
+// identical [Document] values always produce identical bytes. Use Emit when
+// you want stable output for storage, transmission, hashing, or diffs. For
+// human-readable pretty-printing that preserves source layout, comments, and
+// other non-semantic details, use [Format] instead.
+//
+// Output is written in small pieces with [io.WriteString], so a writer that
+// implements [io.StringWriter], such as a *[strings.Builder] or
+// *[bytes.Buffer], receives it without intermediate copies. To add KDL to a
+// larger string being assembled in a strings.Builder, pass the builder to Emit
+// directly. Other writers may be called many times per document; wrap them in
+// a [bufio.Writer] if each Write is costly.
 //
 // By default, the emitter uses an indent of four spaces and standard float
 // formatting. Options can be provided to customize the output.
 //   - [WithVersion] to set the KDL version to emit (default: [Version2]).
 //   - [WithIndent] to set a custom indent string (default: four spaces).
+//   - [WithIndentWidth] and [WithIndentChar] to set the indent as a number of
+//     repeated characters (e.g. two spaces or one tab).
 //   - [WithStringAlwaysQuote] to always quote strings (default: false).
 //   - [WithFloatCapitalExponent] to use capital 'E' for exponents (default: false).
 //   - [WithFloatMinExponent] to set the minimum exponent for using scientific notation (default: 10).
@@ -33,24 +46,27 @@ import (
 //   - [WithFloatExponentPlus] to include '+' for positive exponents (default: false).
 //   - [WithFloatDecimalOrExponent] to always include either a decimal point or exponent part in floats (default: true).
 //     Without this option, integer-like floating points may be reparsed as integers.
+//   - [WithFloatFormat] to set all of the above float options at once.
 //   - [WithEmitEmptyChildren] to emit an empty children block when a node has no children (default: false). Also
 //     configurable at the node level via [Node.Hints].
 //   - [WithIntegerFormat] to set the format to use for integers (default: [Decimal]).
+//   - [WithSortProperties] to emit properties in alphabetical order (default: false).
+//   - [WithEscapeMode] to control which characters are escaped in quoted strings (default: [EscapeDefault]).
+//   - [WithNumberLiterals] to write parsed numbers as they appeared in the source (default: false).
+//   - [WithCanonical] to apply a preset of the above producing a canonical form.
 func Emit(d *Document, w io.Writer, opts ...EmitOption) error {
 	e := &emitter{
-		w:      w,
-		indent: "    ",
-
-		stringAlwaysQuote:      false,
-		floatCapitalExponent:   false,
-		floatMinExponent:       10,
-		floatPlus:              false,
-		floatDecimalPoint:      false,
-		floatExponentPlus:      false,
-		floatDecimalOrExponent: true,
-		version:                Version2,
-		integerFormat:          Decimal,
-		emitEmptyChildren:      false,
+		w:          w,
+		indent:     "    ",
+		indentChar: ' ',
+
+		stringAlwaysQuote: false,
+		floatFormat:       DefaultFloatFormat(),
+		version:           Version2,
+		integerFormat:     Decimal,
+		emitEmptyChildren: false,
+		sortProperties:    false,
+		escapeMode:        EscapeDefault,
 	}
 	for _, opt := range opts {
 		opt.applyEmitter(e)
@@ -69,7 +85,20 @@ func EmitToString(d *Document, opts ...EmitOption) (string, error) {
 	return buf.String(), nil
 }
 
-// IntegerFormat specifies the format to use for emitting integers.
+// EmitBytes is like [Emit] but returns the emitted KDL as a byte slice; see
+// [Emit] for details.
+func EmitBytes(d *Document, opts ...EmitOption) ([]byte, error) {
+	var buf bytes.Buffer
+	err := Emit(d, &buf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// IntegerFormat specifies the format to use for emitting integers. It can be
+// set for a whole document with [WithIntegerFormat] or for a single value with
+// [Value.WithIntegerFormat].
 type IntegerFormat int
 
 const (
@@ -79,21 +108,112 @@ const (
 	Binary
 )
 
+// formatInteger returns the KDL text of an [Int] or [BigInt] Value in format f.
+func formatInteger(v Value, f IntegerFormat) string {
+	var base int
+	var prefix string
+	switch f {
+	case Decimal:
+		if v.kind == Int {
+			return strconv.Itoa(v.raw.(int))
+		}
+		return v.raw.(*big.Int).String()
+	case Hex:
+		base, prefix = 16, "0x"
+	case Octal:
+		base, prefix = 8, "0o"
+	case Binary:
+		base, prefix = 2, "0b"
+	default:
+		panic("kdl.Emit: invalid integer format")
+	}
+
+	var s string
+	negative := false
+	if v.kind == Int {
+		i := int64(v.raw.(int))
+		negative = i < 0
+		if negative {
+			i = -i // wraps for MinInt64, which the uint64 conversion undoes
+		}
+		s = strconv.FormatUint(uint64(i), base)
+	} else {
+		bi := v.raw.(*big.Int)
+		negative = bi.Sign() < 0
+		s = new(big.Int).Abs(bi).Text(base)
+	}
+	if negative {
+		return "-" + prefix + s
+	}
+	return prefix + s
+}
+
+// FloatFormat controls how floating point numbers are emitted. The zero value
+// is not the default; use [DefaultFloatFormat] as a starting point.
+type FloatFormat struct {
+	// CapitalExponent uses 'E' instead of 'e' for exponents.
+	CapitalExponent bool
+	// MinExponent is the minimum (absolute) exponent at which scientific
+	// notation is used. Set to [math.MaxInt] to never use an exponent.
+	MinExponent int
+	// Plus includes a '+' sign for positive floats.
+	Plus bool
+	// DecimalPoint always includes a decimal point, e.g. 1.0E+10.
+	DecimalPoint bool
+	// ExponentPlus includes a '+' sign for positive exponents.
+	ExponentPlus bool
+	// DecimalOrExponent always includes either a decimal point or an exponent,
+	// so that integer-like floats are not reparsed as integers.
+	DecimalOrExponent bool
+}
+
+// DefaultFloatFormat returns the float format used by [Emit] when no float
+// options are given.
+func DefaultFloatFormat() FloatFormat {
+	return FloatFormat{
+		MinExponent:       10,
+		DecimalOrExponent: true,
+	}
+}
+
+// EscapeMode is a set of flags controlling which characters are escaped in
+// quoted strings. Quotes, backslashes, and characters that are not allowed to
+// appear literally in a quoted string (control characters, and newlines in KDL
+// v2) are always escaped regardless of mode.
+type EscapeMode uint8
+
+const (
+	// EscapeTab escapes tabs as \t.
+	EscapeTab EscapeMode = 1 << iota
+	// EscapeNewline escapes newlines as \n, \r, etc. This only makes a difference
+	// for KDL v1, since v2 quoted strings cannot contain literal newlines.
+	EscapeNewline
+	// EscapeASCII escapes all non-ASCII characters as \u{...}.
+	EscapeASCII
+
+	// EscapeMinimal escapes only the characters that must be escaped.
+	EscapeMinimal EscapeMode = 0
+	// EscapeDefault is the default escape mode, escaping tabs and newlines.
+	EscapeDefault = EscapeTab | EscapeNewline
+)
+
 type emitter struct {
 	w           io.Writer
 	indent      string
+	indentChar  rune
 	indentLevel int
+	indents     []string // indents[i] is indent repeated i times
 
-	stringAlwaysQuote      bool
-	floatCapitalExponent   bool
-	floatMinExponent       int
-	floatPlus              bool
-	floatDecimalPoint      bool
-	floatExponentPlus      bool
-	floatDecimalOrExponent bool
-	version                Version
-	integerFormat          IntegerFormat
-	emitEmptyChildren      bool
+	stringAlwaysQuote bool
+	floatFormat       FloatFormat
+	version           Version
+	integerFormat     IntegerFormat
+	emitEmptyChildren bool
+	sortProperties    bool
+	escapeMode        EscapeMode
+	ignoreHints       bool // also ignores formats set with Value.WithIntegerFormat
+	omitComments      bool
+	numberLiterals    bool
 }
 
 // EmitterHints are hints that can be set on a per-node basis to control
@@ -113,7 +233,10 @@ func (e *emitter) emit(s string) error {
 
 func (e *emitter) emitIndent() error {
 	if e.indentLevel > 0 && e.indent != "" {
-		return e.emit(strings.Repeat(e.indent, e.indentLevel))
+		for len(e.indents) <= e.indentLevel {
+			e.indents = append(e.indents, strings.Repeat(e.indent, len(e.indents)))
+		}
+		return e.emit(e.indents[e.indentLevel])
 	}
 	return nil
 }
@@ -128,6 +251,16 @@ func (e *emitter) emitDocument(d *Document) error {
 }
 
 func (e *emitter) emitNode(n *Node) error {
+	if n.comment != "" && !e.omitComments {
+		for _, line := range commentLines(n.comment) {
+			if err := e.emitIndent(); err != nil {
+				return err
+			}
+			if err := e.emit(line + "\n"); err != nil {
+				return err
+			}
+		}
+	}
 	if err := e.emitIndent(); err != nil {
 		return err
 	}
@@ -147,19 +280,66 @@ func (e *emitter) emitNode(n *Node) error {
 		return err
 	}
 
-	for _, a := range n.args {
-		if err := e.emit(" "); err != nil {
+	props := n.propOrder
+	if e.sortProperties {
+		props = slices.Clone(props)
+		slices.Sort(props)
+	}
+	hasChildren := len(n.children.Nodes) > 0 || (n.hints.EmitEmptyChildren && !e.ignoreHints) || e.emitEmptyChildren
+	trailing := ""
+	if n.trailingCommentSet && !e.omitComments {
+		trailing = n.trailingComment.text
+	}
+
+	// continued is set after an argument comment's escline, when the next
+	// token starts a fresh (indented) line and needs no separating space
+	continued := false
+	// openComment is the comment of a last argument followed only by the
+	// children block, which goes after the block's opening brace
+	openComment := ""
+	space := func() error {
+		if continued {
+			continued = false
+			return nil
+		}
+		return e.emit(" ")
+	}
+
+	for i, a := range n.args {
+		if err := space(); err != nil {
 			return err
 		}
 		if err := e.emitValue(a); err != nil {
 			return err
 		}
+		c := n.ArgumentComment(i)
+		if c == "" || e.omitComments {
+			continue
+		}
+		if i == len(n.args)-1 && len(props) == 0 {
+			if hasChildren {
+				openComment = c
+				continue
+			}
+			if trailing == "" {
+				trailing = "// " + c + "\n"
+				continue
+			}
+		}
+		if err := e.emit(" \\ // " + c + "\n"); err != nil {
+			return err
+		}
+		e.indentLevel++
+		err := e.emitIndent()
+		e.indentLevel--
+		if err != nil {
+			return err
+		}
+		continued = true
 	}
 
-	props := slices.Clone(n.propOrder)
-	slices.Sort(props)
 	for _, p := range props {
-		if err := e.emit(" "); err != nil {
+		if err := space(); err != nil {
 			return err
 		}
 		if err := e.emitIdentifier(p); err != nil {
@@ -173,8 +353,15 @@ func (e *emitter) emitNode(n *Node) error {
 		}
 	}
 
-	if len(n.children.Nodes) > 0 || n.hints.EmitEmptyChildren || e.emitEmptyChildren {
-		if err := e.emit(" {\n"); err != nil {
+	if hasChildren {
+		if err := space(); err != nil {
+			return err
+		}
+		open := "{\n"
+		if openComment != "" {
+			open = "{ // " + openComment + "\n"
+		}
+		if err := e.emit(open); err != nil {
 			return err
 		}
 		e.indentLevel++
@@ -190,6 +377,13 @@ func (e *emitter) emitNode(n *Node) error {
 		}
 	}
 
+	if trailing != "" {
+		// trailing already includes the newline
+		if err := space(); err != nil {
+			return err
+		}
+		return e.emit(trailing)
+	}
 	if err := e.emit("\n"); err != nil {
 		return err
 	}
@@ -198,7 +392,7 @@ func (e *emitter) emitNode(n *Node) error {
 }
 
 func (e *emitter) emitIdentifier(s string) error {
-	needsQuoting := e.stringAlwaysQuote || !CanBeBareIdentifier(s, e.version)
+	needsQuoting := e.stringAlwaysQuote || !CanBeBareIdentifier(s, e.version) || e.mustEscapeASCII(s)
 	if needsQuoting {
 		return e.emitString(s)
 	} else {
@@ -207,14 +401,34 @@ func (e *emitter) emitIdentifier(s string) error {
 }
 
 func (e *emitter) emitString(s string) error {
-	needsQuoting := e.stringAlwaysQuote || e.version == Version1 || !CanBeBareIdentifier(s, e.version)
+	needsQuoting := e.stringAlwaysQuote || e.version == Version1 || !CanBeBareIdentifier(s, e.version) || e.mustEscapeASCII(s)
 	if needsQuoting {
-		return e.emit(`"` + EscapeString(s, e.version) + `"`)
+		if err := e.emit(`"`); err != nil {
+			return err
+		}
+		if err := e.emit(EscapeStringMode(s, e.version, e.escapeMode)); err != nil {
+			return err
+		}
+		return e.emit(`"`)
 	} else {
 		return e.emit(s)
 	}
 }
 
+// mustEscapeASCII reports whether s contains non-ASCII characters that must be
+// escaped (and therefore quoted) under [EscapeASCII].
+func (e *emitter) mustEscapeASCII(s string) bool {
+	if e.escapeMode&EscapeASCII == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *emitter) emitValue(v Value) error {
 	if ty, ok := v.TypeAnnotation(); ok {
 		if err := e.emit("("); err != nil {
@@ -227,55 +441,20 @@ func (e *emitter) emitValue(v Value) error {
 			return err
 		}
 	}
+	if e.numberLiterals {
+		if lit, ok := numberLiteral(v); ok {
+			return e.emit(lit)
+		}
+	}
 	switch v.Kind() {
 	case String:
 		return e.emitString(v.String())
-	case Int:
-		switch e.integerFormat {
-		case Decimal:
-			return e.emit(strconv.FormatInt(int64(v.Int()), 10))
-		case Hex:
-			if v.Int() < 0 {
-				return e.emit(fmt.Sprintf("-0x%x", -v.Int()))
-			}
-			return e.emit(fmt.Sprintf("0x%x", v.Int()))
-		case Octal:
-			if v.Int() < 0 {
-				return e.emit(fmt.Sprintf("-0o%o", -v.Int()))
-			}
-			return e.emit(fmt.Sprintf("0o%o", v.Int()))
-		case Binary:
-			if v.Int() < 0 {
-				return e.emit(fmt.Sprintf("-0b%b", -v.Int()))
-			}
-			return e.emit(fmt.Sprintf("0b%b", v.Int()))
-		default:
-			panic("kdl.Emit: emitValue invalid integer format")
-		}
-	case BigInt:
-		if e.integerFormat == Decimal {
-			return e.emit(v.BigInt().String())
-		}
-		var base int
-		var prefix string
-		switch e.integerFormat {
-		case Hex:
-			base = 16
-			prefix = "0x"
-		case Octal:
-			base = 8
-			prefix = "0o"
-		case Binary:
-			base = 2
-			prefix = "0b"
-		default:
-			panic("kdl.Emit: emitValue invalid integer format")
-		}
-		s := prefix + v.BigInt().Text(base)
-		if v.BigInt().Sign() < 0 {
-			s = "-" + s
+	case Int, BigInt:
+		f := e.integerFormat
+		if v.src != nil && v.src.intFormatSet && !e.ignoreHints {
+			f = v.src.intFormat
 		}
-		return e.emit(s)
+		return e.emit(formatInteger(v, f))
 	case Float:
 		if math.IsNaN(v.Float()) {
 			if e.version == Version1 {
@@ -285,9 +464,15 @@ func (e *emitter) emitValue(v Value) error {
 				return e.emit("#nan")
 			}
 		}
-		return e.emitFloat(new(big.Float).SetFloat64(v.Float()))
+		f := v.Float()
+		return e.emitFloat(math.IsInf(f, 0), floatSign(f), func(format byte) string {
+			return strconv.FormatFloat(f, format, -1, 64)
+		})
 	case BigFloat:
-		return e.emitFloat(v.BigFloat())
+		f := v.raw.(*big.Float)
+		return e.emitFloat(f.IsInf(), f.Sign(), func(format byte) string {
+			return f.Text(format, -1)
+		})
 	case Bool:
 		if e.version == Version1 {
 			if v.Bool() {
@@ -313,17 +498,48 @@ func (e *emitter) emitValue(v Value) error {
 	}
 }
 
-func (e *emitter) emitFloat(f *big.Float) error {
-	if f.IsInf() {
+// numberLiteral returns the source text of a parsed number, for
+// [WithNumberLiterals]. Infinities and NaN are excluded since their literals
+// are not valid in every version.
+func numberLiteral(v Value) (string, bool) {
+	switch v.kind {
+	case Int, BigInt, BigFloat:
+		return v.Literal()
+	case Float:
+		if f := v.raw.(float64); math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", false
+		}
+		return v.Literal()
+	default:
+		return "", false
+	}
+}
+
+func floatSign(f float64) int {
+	switch {
+	case f > 0:
+		return 1
+	case f < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// emitFloat emits a float given whether it is infinite, its sign (-1, 0, or
+// +1), and a function formatting it with the given strconv/big format
+// character and the smallest precision that represents it exactly.
+func (e *emitter) emitFloat(inf bool, sign int, text func(format byte) string) error {
+	if inf {
 		if e.version == Version1 {
 			// v1 doesn't have Inf... guess we can emit a string
-			if f.Sign() > 0 {
+			if sign > 0 {
 				return e.emit(`"inf"`)
 			} else {
 				return e.emit(`"-inf"`)
 			}
 		} else {
-			if f.Sign() > 0 {
+			if sign > 0 {
 				return e.emit("#inf")
 			} else {
 				return e.emit("#-inf")
@@ -331,52 +547,46 @@ func (e *emitter) emitFloat(f *big.Float) error {
 		}
 	}
 
-	if e.floatPlus && f.Sign() > 0 {
+	if e.floatFormat.Plus && sign > 0 {
 		if err := e.emit("+"); err != nil {
 			return err
 		}
 	}
 
-	s := f.Text('e', -1)
-	parts := strings.Split(s, "e")
-	if len(parts) != 2 {
+	s := text('e')
+	idx := strings.IndexByte(s, 'e')
+	if idx == -1 {
 		return fmt.Errorf("failed to format float: %s", s)
 	}
 
-	exponent, err := strconv.Atoi(parts[1])
+	exponent, err := strconv.Atoi(s[idx+1:])
 	if err != nil {
 		return err
 	}
 
 	useScientific := true
 	if exponent >= 0 {
-		if exponent < e.floatMinExponent {
+		if exponent < e.floatFormat.MinExponent {
 			useScientific = false
 		}
 	} else {
-		if -exponent < e.floatMinExponent {
+		if -exponent < e.floatFormat.MinExponent {
 			useScientific = false
 		}
 	}
 
 	if !useScientific {
-		s = f.Text('f', -1)
+		s = text('f')
 	} else {
-		formatChar := byte('e')
-		if e.floatCapitalExponent {
-			formatChar = 'E'
+		if !e.floatFormat.ExponentPlus && s[idx+1] == '+' {
+			s = s[:idx+1] + s[idx+2:]
 		}
-		s = f.Text(formatChar, -1)
-
-		if !e.floatExponentPlus {
-			idx := strings.IndexByte(s, formatChar)
-			if idx != -1 && idx+1 < len(s) && s[idx+1] == '+' {
-				s = s[:idx+1] + s[idx+2:]
-			}
+		if e.floatFormat.CapitalExponent {
+			s = s[:idx] + "E" + s[idx+1:]
 		}
 	}
 
-	if e.floatDecimalPoint {
+	if e.floatFormat.DecimalPoint {
 		if !strings.Contains(s, ".") {
 			idx := strings.IndexAny(s, "eE")
 			if idx != -1 {
@@ -385,7 +595,7 @@ func (e *emitter) emitFloat(f *big.Float) error {
 				s = s + ".0"
 			}
 		}
-	} else if e.floatDecimalOrExponent {
+	} else if e.floatFormat.DecimalOrExponent {
 		if !strings.ContainsAny(s, ".eE") {
 			s = s + ".0"
 		}
@@ -2,7 +2,9 @@ package kdl
 
 import (
 	"bytes"
+	"math"
 	"math/big"
+	"strings"
 	"testing"
 )
 
@@ -311,3 +313,577 @@ func TestEmitBareNodeNames(t *testing.T) {
 		})
 	}
 }
+
+func TestEmitPropertyOrder(t *testing.T) {
+	newDoc := func() *Document {
+		n := NewNode("node", NewInt(1))
+		n.AddProperty("zeta", NewInt(1))
+		n.AddProperty("alpha", NewInt(2))
+		n.AddProperty("mid", NewInt(3))
+		return NewDocument(n)
+	}
+
+	tests := []struct {
+		name     string
+		opts     []EmitOption
+		expected string
+	}{
+		{
+			name:     "insertion order by default",
+			expected: "node 1 zeta=1 alpha=2 mid=3\n",
+		},
+		{
+			name:     "sorted when requested",
+			opts:     []EmitOption{WithSortProperties(true)},
+			expected: "node 1 alpha=2 mid=3 zeta=1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := newDoc()
+			got, err := EmitToString(doc, tt.opts...)
+			if err != nil {
+				t.Fatalf("Emit() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Emit() = %q, want %q", got, tt.expected)
+			}
+			if order := doc.Nodes[0].PropertyOrder(); order[0] != "zeta" {
+				t.Errorf("Emit() mutated PropertyOrder: %v", order)
+			}
+		})
+	}
+
+	// round trip preserves source order
+	doc, err := ParseString("node b=1 a=2 c=3\n")
+	if err != nil {
+		t.Fatalf("ParseString() error = %v", err)
+	}
+	got, err := EmitToString(doc)
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if want := "node b=1 a=2 c=3\n"; got != want {
+		t.Errorf("Emit() = %q, want %q", got, want)
+	}
+}
+
+func TestEmitIndentWidth(t *testing.T) {
+	doc := NewDocument(NewNode("parent").AddChild(NewNode("child").AddChild(NewNode("grandchild"))))
+
+	tests := []struct {
+		name     string
+		opts     []EmitOption
+		expected string
+	}{
+		{
+			name:     "default",
+			expected: "parent {\n    child {\n        grandchild\n    }\n}\n",
+		},
+		{
+			name:     "two spaces",
+			opts:     []EmitOption{WithIndentWidth(2)},
+			expected: "parent {\n  child {\n    grandchild\n  }\n}\n",
+		},
+		{
+			name:     "one tab",
+			opts:     []EmitOption{WithIndentChar('\t'), WithIndentWidth(1)},
+			expected: "parent {\n\tchild {\n\t\tgrandchild\n\t}\n}\n",
+		},
+		{
+			name:     "char keeps indent width",
+			opts:     []EmitOption{WithIndent("  "), WithIndentChar('\t')},
+			expected: "parent {\n\t\tchild {\n\t\t\t\tgrandchild\n\t\t}\n}\n",
+		},
+		{
+			name:     "negative clamped",
+			opts:     []EmitOption{WithIndentWidth(-3)},
+			expected: "parent {\nchild {\ngrandchild\n}\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EmitToString(doc, tt.opts...)
+			if err != nil {
+				t.Fatalf("Emit() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Emit() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEmitEscapeMode(t *testing.T) {
+	doc := NewDocument(NewNode("node").AddArgument(NewString("a\tb\nc")))
+
+	tests := []struct {
+		name     string
+		opts     []EmitOption
+		expected string
+	}{
+		{
+			name:     "default",
+			expected: "node \"a\\tb\\nc\"\n",
+		},
+		{
+			name:     "minimal",
+			opts:     []EmitOption{WithEscapeMode(EscapeMinimal)},
+			expected: "node \"a\tb\\nc\"\n",
+		},
+		{
+			name:     "minimal v1",
+			opts:     []EmitOption{WithVersion(Version1), WithEscapeMode(EscapeMinimal)},
+			expected: "node \"a\tb\nc\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EmitToString(doc, tt.opts...)
+			if err != nil {
+				t.Fatalf("Emit() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Emit() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	got, err := EmitToString(NewDocument(NewNode("node").AddArgument(NewString("café"))), WithEscapeMode(EscapeASCII))
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if want := "node \"caf\\u{E9}\"\n"; got != want {
+		t.Errorf("Emit() = %q, want %q", got, want)
+	}
+
+	if EscapeTab != 1 || EscapeNewline != 2 || EscapeASCII != 4 || EscapeMinimal != 0 {
+		t.Errorf("escape flags = %d, %d, %d, %d; want 1, 2, 4, 0", EscapeTab, EscapeNewline, EscapeASCII, EscapeMinimal)
+	}
+}
+
+func TestEmitFloatFormat(t *testing.T) {
+	suite := FloatFormat{
+		CapitalExponent: true,
+		MinExponent:     2,
+		DecimalPoint:    true,
+		ExponentPlus:    true,
+	}
+	noExponent := DefaultFloatFormat()
+	noExponent.MinExponent = math.MaxInt
+
+	tests := []struct {
+		name     string
+		val      float64
+		format   FloatFormat
+		expected string
+	}{
+		{"default simple", 1.5, DefaultFloatFormat(), "node 1.5\n"},
+		{"default integral", 10, DefaultFloatFormat(), "node 10.0\n"},
+		{"default large", 1.5e12, DefaultFloatFormat(), "node 1.5e12\n"},
+		{"suite simple", 1.5, suite, "node 1.5\n"},
+		{"suite large", 1500, suite, "node 1.5E+03\n"},
+		{"suite small", 0.0015, suite, "node 1.5E-03\n"},
+		{"no exponent", 1.5e12, noExponent, "node 1500000000000.0\n"},
+		{"no decimal", 10, FloatFormat{MinExponent: 10}, "node 10\n"},
+		{"plus", 2.5, FloatFormat{MinExponent: 10, Plus: true}, "node +2.5\n"},
+		{"lowercase exponent", 1500, FloatFormat{MinExponent: 2, ExponentPlus: true}, "node 1.5e+03\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := NewDocument(NewNode("node").AddArgument(NewFloat(tt.val)))
+			got, err := EmitToString(doc, WithFloatFormat(tt.format))
+			if err != nil {
+				t.Fatalf("Emit() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Emit() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEmitBytes(t *testing.T) {
+	doc := NewDocument(NewNode("node").AddArgument(NewInt(1)))
+	got, err := EmitBytes(doc)
+	if err != nil {
+		t.Fatalf("EmitBytes() error = %v", err)
+	}
+	if want := "node 1\n"; string(got) != want {
+		t.Errorf("EmitBytes() = %q, want %q", got, want)
+	}
+
+	bad := NewDocument(NewNode("node").AddArgument(Value{}))
+	_, wantErr := EmitToString(bad)
+	got, err = EmitBytes(bad)
+	if err == nil || err.Error() != wantErr.Error() {
+		t.Errorf("EmitBytes() error = %v, want %v", err, wantErr)
+	}
+	if got != nil {
+		t.Errorf("EmitBytes() = %q, want nil on error", got)
+	}
+}
+
+func TestEmitTypeAnnotationRoundTrip(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`node arg=(u8)5`, "node arg=(u8)5\n"},
+		{`node (date)"2020-01-01"`, "node (date)\"2020-01-01\"\n"},
+		{`node (i64)-1 (f32)1.5 (uuid)#null (flag)#true`, "node (i64)-1 (f32)1.5 (uuid)#null (flag)#true\n"},
+		{`node ("my type")1 key=("my type")2`, "node (\"my type\")1 key=(\"my type\")2\n"},
+		{`(t)node (a)1 b=(c)2 { (d)child (e)3 f=(g)"h"; }`, "(t)node (a)1 b=(c)2 {\n    (d)child (e)3 f=(g)h\n}\n"},
+	}
+	for _, tt := range tests {
+		doc, err := ParseString(tt.src)
+		if err != nil {
+			t.Errorf("ParseString(%q) error = %v", tt.src, err)
+			continue
+		}
+		got, err := EmitToString(doc)
+		if err != nil {
+			t.Errorf("Emit(%q) error = %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Emit() = %q, want %q", got, tt.want)
+		}
+
+		reparsed, err := ParseString(got)
+		if err != nil {
+			t.Errorf("ParseString(%q) error = %v", got, err)
+			continue
+		}
+		if !doc.Equal(reparsed) {
+			t.Errorf("round trip of %q changed the document: %q", tt.src, got)
+		}
+
+		formatted, err := FormatToString(doc)
+		if err != nil {
+			t.Errorf("Format(%q) error = %v", tt.src, err)
+			continue
+		}
+		if reparsed, err := ParseString(formatted); err != nil || !doc.Equal(reparsed) {
+			t.Errorf("format round trip of %q changed the document: %q (%v)", tt.src, formatted, err)
+		}
+	}
+}
+
+func TestEmitEmptyChildrenHint(t *testing.T) {
+	doc := NewDocument(
+		NewNode("plain"),
+		NewNode("block").SetEmitEmptyChildren(true),
+		NewNode("parent").AddChild(NewNode("inner").SetEmitEmptyChildren(true)),
+		NewNode("unset").SetEmitEmptyChildren(true).SetEmitEmptyChildren(false),
+	)
+	got, err := EmitToString(doc)
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	want := "plain\nblock {\n}\nparent {\n    inner {\n    }\n}\nunset\n"
+	if got != want {
+		t.Errorf("Emit() = %q, want %q", got, want)
+	}
+}
+
+func TestEmitEmptyChildrenOption(t *testing.T) {
+	doc := NewDocument(
+		NewNode("plain"),
+		NewNode("hinted").SetEmitEmptyChildren(true),
+		NewNode("parent").AddChild(NewNode("leaf", NewInt(1))),
+	)
+
+	tests := []struct {
+		v    bool
+		want string
+	}{
+		{false, "plain\nhinted {\n}\nparent {\n    leaf 1\n}\n"},
+		{true, "plain {\n}\nhinted {\n}\nparent {\n    leaf 1 {\n    }\n}\n"},
+	}
+	for _, tt := range tests {
+		got, err := EmitToString(doc, WithEmitEmptyChildren(tt.v))
+		if err != nil {
+			t.Fatalf("Emit() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Emit(WithEmitEmptyChildren(%v)) = %q, want %q", tt.v, got, tt.want)
+		}
+		if _, err := ParseString(got); err != nil {
+			t.Errorf("Emit() output does not parse: %v", err)
+		}
+	}
+}
+
+func TestEscapeStringModeUnchanged(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"plain text", "plain text"},
+		{"ünïcode", "ünïcode"},
+		{`"`, `\"`},
+		{`a\b`, `a\\b`},
+		{"tab\there", `tab\there`},
+		{"end\n", `end\n`},
+		{"bad\xffutf8", "bad\uFFFDutf8"},
+		{"\u200Ebidi", `\u{200E}bidi`},
+	}
+	for _, tt := range tests {
+		if got := EscapeStringMode(tt.in, Version2, EscapeDefault); got != tt.want {
+			t.Errorf("EscapeStringMode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if got := EscapeStringMode("ünï", Version2, EscapeASCII); got != `\u{FC}n\u{EF}` {
+		t.Errorf("EscapeStringMode(EscapeASCII) = %q", got)
+	}
+}
+
+func TestEmitAnnotatedNull(t *testing.T) {
+	doc := NewDocument(NewNode("node").AddArgument(NewNullAnnotated("maybe")).AddProperty("k", NewNull()))
+	got, err := EmitToString(doc)
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if want := "node (maybe)#null k=#null\n"; got != want {
+		t.Errorf("Emit() = %q, want %q", got, want)
+	}
+	if ty, ok := NewNull().TypeAnnotation(); ok {
+		t.Errorf("NewNull() has type annotation %q after NewNullAnnotated", ty)
+	}
+}
+
+func TestEmitCanonical(t *testing.T) {
+	sources := []string{
+		"(t)node 1 b=0x10 a=1.50 { child; }\nother \"x\"\n",
+		"(\"t\")\"node\" a=1.5 0b1 b=16 {\n    child {}\n}\n/- skipped\nother x // comment\n",
+		"/- kdl-version 1\n(t)node 1 b=16 a=1.5 {\n    child\n}\nother \"x\"\n",
+	}
+	want := "(\"t\")\"node\" 1 \"a\"=1.5 \"b\"=16 {\n    \"child\"\n}\n\"other\" \"x\"\n"
+
+	for _, src := range sources {
+		doc, err := ParseString(src)
+		if err != nil {
+			t.Fatalf("ParseString(%q) error = %v", src, err)
+		}
+		doc.Nodes[0].SetEmitEmptyChildren(true).Children().Nodes[0].SetEmitEmptyChildren(true)
+		got, err := EmitToString(doc, WithCanonical())
+		if err != nil {
+			t.Fatalf("Emit(%q) error = %v", src, err)
+		}
+		if got != want {
+			t.Errorf("canonical Emit(%q) = %q, want %q", src, got, want)
+		}
+
+		reparsed, err := ParseString(got)
+		if err != nil {
+			t.Fatalf("ParseString(%q) error = %v", got, err)
+		}
+		again, err := EmitToString(reparsed, WithCanonical())
+		if err != nil || again != got {
+			t.Errorf("canonical form is not stable: %q -> %q, %v", got, again, err)
+		}
+	}
+
+	// comments set programmatically are not part of the canonical form
+	commented := NewDocument(NewNode("n", NewInt(1), NewInt(2)).
+		SetComment("hi").
+		SetArgumentComment(0, "first").
+		SetArgumentComment(1, "second"),
+		NewNode("m").SetTrailingComment("x"))
+	if got, err := EmitToString(commented, WithCanonical()); err != nil || got != "\"n\" 1 2\n\"m\"\n" {
+		t.Errorf("canonical Emit() = %q, %v; want %q", got, err, "\"n\" 1 2\n\"m\"\n")
+	}
+
+	// per-value integer formats are ignored too
+	formatted := NewDocument(NewNode("n", NewInt(255).WithIntegerFormat(Hex), NewBigInt(big.NewInt(-8)).WithIntegerFormat(Octal)))
+	if got, err := EmitToString(formatted, WithCanonical()); err != nil || got != "\"n\" 255 -8\n" {
+		t.Errorf("canonical Emit() = %q, %v; want %q", got, err, "\"n\" 255 -8\n")
+	}
+}
+
+func TestEmitNodeComment(t *testing.T) {
+	doc := NewDocument(
+		NewNode("plain"),
+		NewNode("port", NewInt(8080)).SetComment("the port to listen on"),
+		NewNode("parent").AddChild(NewNode("inner").SetComment("first line\n\nthird line\r\nfourth\u2028fifth")),
+		NewNode("unset").SetComment("gone").SetComment(""),
+	)
+	want := "plain\n" +
+		"// the port to listen on\n" +
+		"port 8080\n" +
+		"parent {\n" +
+		"    // first line\n" +
+		"    //\n" +
+		"    // third line\n" +
+		"    // fourth\n" +
+		"    // fifth\n" +
+		"    inner\n" +
+		"}\n" +
+		"unset\n"
+
+	got, err := EmitToString(doc)
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Emit() = %q, want %q", got, want)
+	}
+	if got := mustFormat(t, doc, WithFormatIndentStr("    ")); got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+	reparsed, err := ParseString(got)
+	if err != nil {
+		t.Fatalf("Emit() output does not parse: %v", err)
+	}
+	if len(reparsed.Nodes) != 4 {
+		t.Errorf("reparsed %d nodes, want 4", len(reparsed.Nodes))
+	}
+	if c := doc.Nodes[1].Clone().Comment(); c != "the port to listen on" {
+		t.Errorf("Clone().Comment() = %q", c)
+	}
+}
+
+func TestEmitTrailingComments(t *testing.T) {
+	doc := NewDocument(
+		NewNode("port", NewInt(8080)).SetArgumentComment(0, "default"),
+		NewNode("host", NewString("localhost")).SetTrailingComment("dev only\nreally"),
+		NewNode("range", NewInt(1), NewInt(10)).SetArgumentComment(0, "min").SetArgumentComment(1, "max"),
+		NewNode("opt", NewInt(1)).AddProperty("k", NewInt(2)).SetArgumentComment(0, "arg"),
+		NewNode("both", NewInt(1)).SetArgumentComment(0, "arg").SetTrailingComment("node"),
+		NewNode("parent", NewInt(1)).SetArgumentComment(0, "arg").AddChild(NewNode("inner")),
+		NewNode("removed", NewInt(1), NewInt(2)).SetArgumentComment(0, "gone").RemoveArgument(0),
+		NewNode("out-of-range").SetArgumentComment(0, "ignored"),
+	)
+	want := "port 8080 // default\n" +
+		"host localhost // dev only really\n" +
+		"range 1 \\ // min\n" +
+		"    10 // max\n" +
+		"opt 1 \\ // arg\n" +
+		"    k=2\n" +
+		"both 1 \\ // arg\n" +
+		"    // node\n" +
+		"parent 1 { // arg\n" +
+		"    inner\n" +
+		"}\n" +
+		"removed 2\n" +
+		"out-of-range\n"
+
+	got, err := EmitToString(doc)
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Emit() = %q, want %q", got, want)
+	}
+	// Format keeps a children block after an argument comment multiline
+	if got := mustFormat(t, doc, WithFormatIndentStr("    ")); got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+	reparsed, err := ParseString(got)
+	if err != nil {
+		t.Fatalf("Emit() output does not parse: %v", err)
+	}
+	if diff, _ := Diff(doc, reparsed); len(diff) != 0 {
+		t.Errorf("reparsed document differs: %v", diff)
+	}
+	// the comment after an opening brace is kept as a comment in the block
+	if got, want := mustFormat(t, reparsed), "parent 1 {\n\t// arg\n\tinner\n}\n"; !strings.Contains(got, want) {
+		t.Errorf("Format() of reparsed output = %q, want it to contain %q", got, want)
+	}
+
+	parsed := parseDoc(t, "node 1 // from source\n")
+	if got, _ := EmitToString(parsed); got != "node 1\n" {
+		t.Errorf("Emit() of parsed trailing comment = %q, want %q", got, "node 1\n")
+	}
+}
+
+func TestEmitIntegerFormat(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	values := []Value{NewInt(255), NewInt(-10), NewInt(math.MinInt64), NewBigInt(huge)}
+	tests := []struct {
+		format IntegerFormat
+		want   string
+	}{
+		{Decimal, "n 255 -10 -9223372036854775808 -123456789012345678901234567890\n"},
+		{Hex, "n 0xff -0xa -0x8000000000000000 -0x18ee90ff6c373e0ee4e3f0ad2\n"},
+		{Octal, "n 0o377 -0o12 -0o1000000000000000000000 -0o143564417755415637016711617605322\n"},
+		{Binary, "n 0b11111111 -0b1010 -0b1" + strings.Repeat("0", 63) + " -0b" + huge.Text(2)[1:] + "\n"},
+	}
+	for _, tt := range tests {
+		doc := NewDocument(NewNode("n", values...))
+		got, err := EmitToString(doc, WithIntegerFormat(tt.format))
+		if err != nil {
+			t.Fatalf("Emit() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Emit(WithIntegerFormat(%d)) = %q, want %q", tt.format, got, tt.want)
+		}
+		reparsed, err := ParseString(got)
+		if err != nil {
+			t.Fatalf("Emit() output does not parse: %v", err)
+		}
+		if !reparsed.Nodes[0].Equal(doc.Nodes[0]) {
+			t.Errorf("reparsed %v, want %v", reparsed.Nodes[0], doc.Nodes[0])
+		}
+
+		doc = NewDocument(NewNode("n").AddArgument(NewInt(255).WithIntegerFormat(tt.format)).AddArgument(NewInt(255)))
+		want := strings.SplitN(tt.want, " ", 3)[1] + " 255\n"
+		if got, _ := EmitToString(doc); got != "n "+want {
+			t.Errorf("Emit() of %d value = %q, want %q", tt.format, got, "n "+want)
+		}
+		if got := mustFormat(t, doc); got != "n "+want {
+			t.Errorf("Format() of %d value = %q, want %q", tt.format, got, "n "+want)
+		}
+	}
+
+	// a per-value format wins over the option and a parsed literal
+	doc := parseDoc(t, "n 0x10\n")
+	doc.Nodes[0].SetArg(0, doc.Nodes[0].Arg(0).WithIntegerFormat(Octal))
+	if got, _ := EmitToString(doc, WithIntegerFormat(Binary)); got != "n 0o20\n" {
+		t.Errorf("Emit() = %q, want %q", got, "n 0o20\n")
+	}
+	if got := mustFormat(t, doc); got != "n 0o20\n" {
+		t.Errorf("Format() = %q, want %q", got, "n 0o20\n")
+	}
+}
+
+func TestEmitNumberLiterals(t *testing.T) {
+	src := "n 0xFF 1_000 1.50e3 -0b101 #inf 42 big=0x1_0000_0000_0000_0000\n"
+	tests := []struct {
+		opts []EmitOption
+		want string
+	}{
+		{nil, "n 255 1000 1500.0 -5 #inf 42 big=18446744073709551616\n"},
+		{[]EmitOption{WithNumberLiterals(true)}, src},
+		{[]EmitOption{WithNumberLiterals(true), WithCanonical()}, "\"n\" 255 1000 1500.0 -5 #inf 42 \"big\"=18446744073709551616\n"},
+	}
+	for _, tt := range tests {
+		doc := parseDoc(t, src)
+		got, err := EmitToString(doc, tt.opts...)
+		if err != nil {
+			t.Fatalf("Emit() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Emit() = %q, want %q", got, tt.want)
+		}
+	}
+
+	// values without source text are formatted as usual
+	doc := parseDoc(t, src)
+	n := doc.Nodes[0]
+	n.SetArg(1, NewInt(7))
+	n.SetArg(3, n.Arg(3).WithIntegerFormat(Hex))
+	got, err := EmitToString(doc, WithNumberLiterals(true))
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	want := "n 0xFF 7 1.50e3 -0x5 #inf 42 big=0x1_0000_0000_0000_0000\n"
+	if got != want {
+		t.Errorf("Emit() = %q, want %q", got, want)
+	}
+}
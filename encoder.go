@@ -43,35 +43,12 @@ func EncodeToString(v any, opts ...EncodeOption) (string, error) {
 }
 
 // Marshal marshals the given value into a KDL Document. v must be a struct,
-// map, or [DocumentMarshaler]. See [Encode] for details on marshaling behavior.
+// map, or [DocumentMarshaler]. See [Encode] for details on marshaling behavior,
+// and [MarshalNode] to marshal v as a single node instead.
 func Marshal(v any, opts ...MarshalOption) (*Document, error) {
-	var target reflect.Value
-	if rv, ok := v.(reflect.Value); ok {
-		target = rv
-	} else {
-		target = reflect.ValueOf(v)
-		if !target.IsValid() {
-			return nil, fmt.Errorf("cannot marshal nil value")
-		}
-		for target.Kind() == reflect.Pointer {
-			target = target.Elem()
-			if !target.IsValid() {
-				return nil, fmt.Errorf("cannot marshal nil pointer")
-			}
-		}
-
-		if target.Kind() == reflect.Interface {
-			target = target.Elem()
-			if !target.IsValid() {
-				return nil, fmt.Errorf("cannot marshal nil interface")
-			}
-			for target.Kind() == reflect.Pointer {
-				target = target.Elem()
-				if !target.IsValid() {
-					return nil, fmt.Errorf("cannot marshal nil pointer inside interface")
-				}
-			}
-		}
+	target, err := marshalTarget(v)
+	if err != nil {
+		return nil, err
 	}
 
 	doc := &Document{}
@@ -121,6 +98,76 @@ func Marshal(v any, opts ...MarshalOption) (*Document, error) {
 	return doc, nil
 }
 
+// MarshalNode marshals the given value into a single KDL node with the given
+// name, for a value that represents one entity rather than a whole document.
+// Where [Marshal] turns the fields of a struct into the top-level nodes of a
+// document, MarshalNode turns them into the arguments, properties, and
+// children of one node, exactly as for a struct-typed field of a struct passed
+// to Marshal. v may also be a map, a slice, a scalar, or a type implementing
+// one of the marshaler interfaces; a [Marshaler] that returns an unnamed node
+// gets the given name, while one that names its node keeps that name. See
+// [Encode] for details on marshaling behavior.
+//
+// MarshalNode is to [Marshal] what [Unmarshal] is to [UnmarshalDocument], so
+// MarshalNode followed by Unmarshal round-trips v.
+func MarshalNode(name string, v any, opts ...MarshalOption) (*Node, error) {
+	target, err := marshalTarget(v)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
+	e := &encoder{
+		stack: []*Document{doc},
+	}
+	for _, opt := range opts {
+		opt.applyMarshaler(e)
+	}
+	defer un(e.trace("MarshalNode %s %s", name, target.Type()))
+
+	if err := e.encodeValueAsNode(name, structTag{}, target); err != nil {
+		return nil, err
+	}
+	if len(doc.Nodes) != 1 {
+		return nil, fmt.Errorf("kdl.MarshalNode: %s marshaled to %d nodes, want 1", target.Type(), len(doc.Nodes))
+	}
+	return doc.Nodes[0], nil
+}
+
+// marshalTarget returns the value to marshal for v, dereferencing pointers and
+// interfaces.
+func marshalTarget(v any) (reflect.Value, error) {
+	var target reflect.Value
+	if rv, ok := v.(reflect.Value); ok {
+		target = rv
+	} else {
+		target = reflect.ValueOf(v)
+		if !target.IsValid() {
+			return reflect.Value{}, fmt.Errorf("cannot marshal nil value")
+		}
+		for target.Kind() == reflect.Pointer {
+			target = target.Elem()
+			if !target.IsValid() {
+				return reflect.Value{}, fmt.Errorf("cannot marshal nil pointer")
+			}
+		}
+
+		if target.Kind() == reflect.Interface {
+			target = target.Elem()
+			if !target.IsValid() {
+				return reflect.Value{}, fmt.Errorf("cannot marshal nil interface")
+			}
+			for target.Kind() == reflect.Pointer {
+				target = target.Elem()
+				if !target.IsValid() {
+					return reflect.Value{}, fmt.Errorf("cannot marshal nil pointer inside interface")
+				}
+			}
+		}
+	}
+	return target, nil
+}
+
 type encoder struct {
 	stack       []*Document // required
 	traceWriter io.Writer
@@ -165,15 +212,17 @@ func (e *encoder) encodeStructFieldsAsNodes(target reflect.Value) error {
 		return err
 	}
 
-	for i := range target.NumField() {
-		tag := ctx.tags[i]
+	for i, tag := range ctx.tags {
 		if tag.name == "" || tag.name == "-" {
 			continue
 		}
-		field := target.Field(i)
+		field, ok := ctx.fieldIfPresent(target, i)
+		if !ok {
+			continue
+		}
 		if !field.CanInterface() {
 			panic(fmt.Sprintf("kdl.Encode: encodeStructFieldsAsNodes: unexported field %s.%s, should be unreachable",
-				target.Type(), target.Type().Field(i).Name))
+				target.Type(), ctx.fields[i].name))
 		}
 
 		if isOmitZero(tag.flags, field) {
@@ -198,15 +247,17 @@ func (e *encoder) encodeStructAsNode(name string, target reflect.Value) error {
 
 	node := NewNode(name)
 
-	for fieldIndex := range target.Type().NumField() {
-		tag := ctx.tags[fieldIndex]
+	for fieldIndex, tag := range ctx.tags {
 		if tag.name == "-" {
 			continue
 		}
-		field := target.Field(fieldIndex)
+		field, ok := ctx.fieldIfPresent(target, fieldIndex)
+		if !ok {
+			continue
+		}
 		if !field.CanInterface() {
 			panic(fmt.Sprintf("kdl.Encode: encodeStructAsNode: unexported field %s.%s, should be unreachable",
-				target.Type(), target.Type().Field(fieldIndex).Name))
+				target.Type(), ctx.fields[fieldIndex].name))
 		}
 
 		if isOmitZero(tag.flags, field) {
@@ -252,8 +303,8 @@ func (e *encoder) encodeStructAsNode(name string, target reflect.Value) error {
 			e.tracef("properties: %s %s\n", tag.name, field.Type())
 			switch field.Kind() {
 			case reflect.Map:
-				for _, key := range field.MapKeys() {
-					val := field.MapIndex(key)
+				for _, key := range sortedMapKeys(field) {
+					val := field.MapIndex(key.Value)
 					if isOmitZero(tag.flags, val) {
 						continue
 					}
@@ -261,7 +312,7 @@ func (e *encoder) encodeStructAsNode(name string, target reflect.Value) error {
 					if err != nil {
 						return err
 					}
-					node.AddProperty(fmt.Sprint(key.Interface()), value)
+					node.AddProperty(key.string, value)
 				}
 			case reflect.Struct:
 				err := e.encodeStructIntoProperties(node, field)
@@ -351,8 +402,9 @@ type mapKey struct {
 	reflect.Value
 }
 
-func (e *encoder) encodeMapEntriesAsNodes(target reflect.Value) error {
-	defer un(e.trace("encodeMapEntriesAsNodes %s", target.Type()))
+// sortedMapKeys returns the keys of the map target sorted by their string form,
+// so that map entries are always encoded in a deterministic order.
+func sortedMapKeys(target reflect.Value) []mapKey {
 	keys := make([]mapKey, 0, target.Len())
 	for _, key := range target.MapKeys() {
 		keys = append(keys, mapKey{
@@ -363,8 +415,12 @@ func (e *encoder) encodeMapEntriesAsNodes(target reflect.Value) error {
 	slices.SortStableFunc(keys, func(a, b mapKey) int {
 		return strings.Compare(a.string, b.string)
 	})
+	return keys
+}
 
-	for _, key := range keys {
+func (e *encoder) encodeMapEntriesAsNodes(target reflect.Value) error {
+	defer un(e.trace("encodeMapEntriesAsNodes %s", target.Type()))
+	for _, key := range sortedMapKeys(target) {
 		val := target.MapIndex(key.Value)
 		err := e.encodeValueAsNode(key.string, structTag{}, val)
 		if err != nil {
@@ -465,15 +521,17 @@ func (e *encoder) encodeStructIntoProperties(node *Node, target reflect.Value) e
 		return err
 	}
 
-	for i := range target.NumField() {
-		tag := ctx.tags[i]
+	for i, tag := range ctx.tags {
 		if tag.name == "" || tag.name == "-" {
 			continue
 		}
-		field := target.Field(i)
+		field, ok := ctx.fieldIfPresent(target, i)
+		if !ok {
+			continue
+		}
 		if !field.CanInterface() {
 			panic(fmt.Sprintf("kdl.Encode: encodeStructIntoProperties: unexported field %s.%s, should be unreachable",
-				target.Type(), target.Type().Field(i).Name))
+				target.Type(), ctx.fields[i].name))
 		}
 
 		if isOmitZero(tag.flags, field) {
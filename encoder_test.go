@@ -72,6 +72,13 @@ func (e *EncoderCustomNodeMarshaler) MarshalKDL() (*kdl.Node, error) {
 	return node, nil
 }
 
+// EncoderUnnamedNodeMarshaler leaves naming its node to the caller.
+type EncoderUnnamedNodeMarshaler int
+
+func (e EncoderUnnamedNodeMarshaler) MarshalKDL() (*kdl.Node, error) {
+	return kdl.NewNode("", kdl.NewInt(int(e))), nil
+}
+
 type EncoderCustomValueMarshaler string
 
 var _ kdl.ValueMarshaler = EncoderCustomValueMarshaler("")
@@ -145,7 +152,7 @@ var encoderTests = []struct {
 			},
 		},
 		`
-			person Dave age=40 job=Engineer hobby=golf
+			person Dave age=40 hobby=golf job=Engineer
 			person Eve married=#true extra1 extra2
 		`,
 	},
@@ -345,3 +352,59 @@ func roundtrip(doc string) (string, error) {
 	}
 	return buf.String(), nil
 }
+
+func TestMarshalNode(t *testing.T) {
+	person := EncoderPersonProps{Name: "alice", Age: 30, Extra: map[string]string{"role": "admin"}}
+	node, err := kdl.MarshalNode("person", &person)
+	if err != nil {
+		t.Fatalf("MarshalNode() error = %v", err)
+	}
+	got, err := kdl.EmitToString(kdl.NewDocument(node))
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if want := "person alice age=30 role=admin\n"; got != want {
+		t.Errorf("MarshalNode() = %q, want %q", got, want)
+	}
+
+	var back EncoderPersonProps
+	if err := kdl.Unmarshal(node, &back); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if back.Name != person.Name || back.Age != person.Age || back.Extra["role"] != "admin" {
+		t.Errorf("Unmarshal() = %+v, want %+v", back, person)
+	}
+
+	// compare with Marshal, which emits the fields as top-level nodes
+	book := EncoderBook{Title: "KDL", Author: EncoderPerson{Name: "bob"}}
+	node, err = kdl.MarshalNode("book", book)
+	if err != nil {
+		t.Fatalf("MarshalNode() error = %v", err)
+	}
+	got, _ = kdl.EmitToString(kdl.NewDocument(node))
+	if want := "book {\n    title KDL\n    author {\n        name bob\n    }\n}\n"; got != want {
+		t.Errorf("MarshalNode() = %q, want %q", got, want)
+	}
+
+	// a Marshaler's unnamed node gets the given name, a named one keeps its own
+	for _, test := range []struct {
+		v    any
+		want string
+	}{
+		{EncoderUnnamedNodeMarshaler(3), "widget 3\n"},
+		{&EncoderCustomNodeMarshaler{Foo: "x", Bar: 1}, "custom x-custom bar=10\n"},
+	} {
+		node, err = kdl.MarshalNode("widget", test.v)
+		if err != nil {
+			t.Fatalf("MarshalNode(%T) error = %v", test.v, err)
+		}
+		got, _ = kdl.EmitToString(kdl.NewDocument(node))
+		if got != test.want {
+			t.Errorf("MarshalNode(%T) = %q, want %q", test.v, got, test.want)
+		}
+	}
+
+	if _, err := kdl.MarshalNode("p", (*EncoderPerson)(nil)); err == nil {
+		t.Error("MarshalNode(nil pointer) succeeded, want an error")
+	}
+}
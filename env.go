@@ -0,0 +1,131 @@
+package kdl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExpandEnv replaces ${VAR} references in the string values of doc with the
+// values of environment variables, as [ExpandEnvFunc] does with a lookup that
+// treats unset variables as empty, like [os.Getenv].
+func ExpandEnv(doc *Document) error {
+	return ExpandEnvFunc(doc, func(name string) (string, bool) {
+		return os.Getenv(name), true
+	})
+}
+
+// ExpandEnvFunc replaces each ${NAME} reference in the string arguments and
+// property values of doc and its descendants with the result of lookup(NAME),
+// so that a configuration file can refer to its environment:
+//
+//	database host="${DB_HOST}" password="${DB_PASSWORD}"
+//
+// Only [String] values are changed; their type annotations are kept, and node
+// names, property keys, and comments are left alone. A $ that is not followed
+// by { is kept as is; to write a literal ${, double the dollar sign, as in
+// "$${NAME}". Expanded strings are not scanned again, so a variable's value
+// may itself contain ${.
+//
+// ExpandEnvFunc returns an error giving the value's location if lookup returns
+// false, which lets callers reject undefined variables, or if a reference is
+// unterminated or has an empty name. doc may be partially expanded when an
+// error is returned.
+func ExpandEnvFunc(doc *Document, lookup func(name string) (string, bool)) error {
+	if err := expandEnvNodes(doc.Nodes, lookup); err != nil {
+		return fmt.Errorf("kdl.ExpandEnv: %w", err)
+	}
+	return nil
+}
+
+func expandEnvNodes(nodes []*Node, lookup func(string) (string, bool)) error {
+	for _, n := range nodes {
+		expand := func(v *Value) error {
+			if v.kind != String {
+				return nil
+			}
+			loc := v.Location()
+			if loc.Line == 0 {
+				loc = n.loc
+			}
+			s, err := expandEnvString(v.raw.(string), lookup)
+			if err != nil {
+				return fmt.Errorf("%s: %w", loc, err)
+			}
+			if s != v.raw.(string) {
+				*v = v.withString(s)
+			}
+			return nil
+		}
+		for i := range n.args {
+			if err := expand(&n.args[i]); err != nil {
+				return err
+			}
+		}
+		for i := range n.propEntries {
+			if err := expand(&n.propEntries[i].value); err != nil {
+				return err
+			}
+		}
+		for _, key := range n.propOrder {
+			v := n.props[key]
+			if err := expand(&v); err != nil {
+				return err
+			}
+			n.props[key] = v
+		}
+		if err := expandEnvNodes(n.children.Nodes, lookup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withString returns a copy of the String value v holding s instead, keeping
+// its type annotation and locations but not its source literal.
+func (v Value) withString(s string) Value {
+	v.raw = s
+	if v.src != nil {
+		src := *v.src
+		src.literal = ""
+		v.src = &src
+	}
+	return v
+}
+
+func expandEnvString(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			// $${ is an escaped ${; s[:i] already ends in one $
+			sb.WriteString(s[:i])
+			sb.WriteByte('{')
+			s = s[i+2:]
+			continue
+		}
+		sb.WriteString(s[:i])
+		end := strings.IndexByte(s[i+2:], '}')
+		if end < 0 {
+			return "", errors.New("unterminated ${ in string")
+		}
+		name := s[i+2 : i+2+end]
+		if name == "" {
+			return "", errors.New("empty variable name in ${}")
+		}
+		value, ok := lookup(name)
+		if !ok {
+			return "", fmt.Errorf("undefined variable %q", name)
+		}
+		sb.WriteString(value)
+		s = s[i+3+end:]
+	}
+}
@@ -0,0 +1,74 @@
+package kdl_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/calico32/kdl-go"
+)
+
+func TestExpandEnv(t *testing.T) {
+	vars := map[string]string{"DB_HOST": "db.local", "USER": "app", "NESTED": "${USER}"}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	doc, err := kdl.ParseString(`
+database (host)"${DB_HOST}" user="${USER}@${DB_HOST}" port=5432 {
+    note "costs $5, literal $${USER}, nested ${NESTED}"
+    raw #"${USER}"#
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kdl.ExpandEnvFunc(doc, lookup); err != nil {
+		t.Fatalf("ExpandEnvFunc() error = %v", err)
+	}
+	got, err := kdl.EmitToString(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `database (host)db.local user=app@db.local port=5432 {
+    note "costs $5, literal ${USER}, nested ${USER}"
+    raw app
+}
+`
+	if got != want {
+		t.Errorf("ExpandEnvFunc() =\n%s\nwant:\n%s", got, want)
+	}
+	if got := doc.Nodes[0].Prop("user").String(); got != "app@db.local" {
+		t.Errorf("Prop(user) = %q, want app@db.local", got)
+	}
+
+	t.Setenv("KDL_TEST_EXPAND", "from-env")
+	envDoc, err := kdl.ParseString(`a "${KDL_TEST_EXPAND}" "${KDL_TEST_UNSET_VAR}"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kdl.ExpandEnv(envDoc); err != nil {
+		t.Fatalf("ExpandEnv() error = %v", err)
+	}
+	if got := envDoc.String(); got != "a from-env \"\"\n" {
+		t.Errorf("ExpandEnv() = %q", got)
+	}
+
+	errTests := []struct {
+		src, want string
+	}{
+		{"a\nb x=\"${MISSING}\"", `kdl.ExpandEnv: <input>:2:5: undefined variable "MISSING"`},
+		{`a "${USER"`, "unterminated ${ in string"},
+		{`a "${}"`, "empty variable name in ${}"},
+	}
+	for _, tt := range errTests {
+		doc, err := kdl.ParseString(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = kdl.ExpandEnvFunc(doc, lookup)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ExpandEnvFunc(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
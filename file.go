@@ -0,0 +1,89 @@
+package kdl
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ReadFile reads and parses the KDL document in the named file. The file name
+// is used as the source name in errors and node locations unless overridden
+// with [WithSourceName].
+func ReadFile(path string, opts ...ParseOption) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f, append([]ParseOption{WithSourceName(path)}, opts...)...)
+}
+
+// WriteFile emits doc to the named file, creating it with permissions 0644 if
+// it does not exist and truncating it otherwise. The file is synced to stable
+// storage before WriteFile returns. Any [EmitOption] may be given (see [Emit]),
+// as well as [WithAtomicWrite].
+//
+// If [WithAtomicWrite] is given, the document is instead written to a temporary
+// file in the same directory, which is then renamed over path, so that readers
+// never observe a partially written file; an existing file's permissions are
+// preserved, and the directory is synced after the rename so that the rename
+// itself is durable.
+func WriteFile(path string, doc *Document, opts ...WriteFileOption) error {
+	atomic, emitOpts := splitWriteFileOptions(opts)
+	if !atomic {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		return errors.Join(writeFile(f, doc, emitOpts), f.Close())
+	}
+
+	perm := fs.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	err = writeFile(f, doc, emitOpts)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	err = errors.Join(err, f.Close())
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir syncs the directory dir, making a rename into it durable. Windows
+// cannot sync directories, so syncDir does nothing there.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	return errors.Join(d.Sync(), d.Close())
+}
+
+func writeFile(f *os.File, doc *Document, opts []EmitOption) error {
+	w := bufio.NewWriter(f)
+	if err := Emit(doc, w, opts...); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
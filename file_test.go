@@ -0,0 +1,69 @@
+package kdl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	doc := NewDocument(NewNode("server", NewString("main")).AddProperty("port", NewInt(80)))
+
+	for _, atomic := range []bool{false, true} {
+		path := filepath.Join(dir, "config.kdl")
+		if err := os.WriteFile(path, []byte("old contents that are longer than the new ones\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := WriteFile(path, doc, WithAtomicWrite(atomic), WithVersion(Version1)); err != nil {
+			t.Fatalf("WriteFile(atomic=%v) error = %v", atomic, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "server \"main\" port=80\n"; string(data) != want {
+			t.Errorf("WriteFile(atomic=%v) wrote %q, want %q", atomic, data, want)
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("WriteFile(atomic=%v) changed permissions: %v, %v", atomic, info.Mode(), err)
+		}
+
+		got, err := ReadFile(path, WithVersion(Version1))
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if !got.Equal(doc) {
+			t.Errorf("ReadFile() = %v, want %v", got, doc)
+		}
+		if got.Nodes[0].Location().Filename != path {
+			t.Errorf("ReadFile() source name = %q, want %q", got.Nodes[0].Location().Filename, path)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("directory holds %v (%v), want only config.kdl", entries, err)
+	}
+
+	bad := NewDocument(NewNode("n").AddArgument(Value{}))
+	path := filepath.Join(dir, "config.kdl")
+	if err := WriteFile(path, bad, WithAtomicWrite(true)); err == nil {
+		t.Error("WriteFile(bad) succeeded, want an error")
+	}
+	if data, _ := os.ReadFile(path); !strings.HasPrefix(string(data), "server") {
+		t.Errorf("failed atomic WriteFile clobbered the file: %q", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("failed atomic WriteFile left files behind: %v", entries)
+	}
+
+	if _, err := ReadFile(filepath.Join(dir, "missing.kdl")); !os.IsNotExist(err) {
+		t.Errorf("ReadFile(missing) error = %v, want a not-exist error", err)
+	}
+	if _, err := ReadFile(path, WithVersion(Version2)); err != nil {
+		t.Errorf("ReadFile(v2) error = %v", err)
+	}
+}
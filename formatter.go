@@ -6,7 +6,6 @@ import (
 	"math"
 	"math/big"
 	"slices"
-	"strconv"
 	"strings"
 )
 
@@ -17,8 +16,8 @@ import (
 // others — and wraps long lines for readability. Use Format when writing files
 // humans will edit, when round-tripping a parsed document back to disk, or
 // wherever output fidelity to the original source matters more than minimal
-// byte output. For canonical, minimal, deterministic output (properties sorted,
-// no comments, no layout preservation), use [Emit] instead.
+// byte output. For minimal, deterministic output (no comments, no layout
+// preservation), use [Emit] instead.
 //
 // Default style:
 //   - indentation with tabs
@@ -79,6 +78,9 @@ type formatter struct {
 
 	indentLevel int
 	lineLen     int // bytes written since last \n
+	// continued is set after an argument comment's escline; the next write
+	// starts the continuation line and drops its leading separator space.
+	continued bool
 }
 
 // bodyPlan returns the sequence of nodeEntryKind slots describing how to
@@ -142,6 +144,10 @@ func (f *formatter) slashdashArgPrefix() string {
 }
 
 func (f *formatter) write(s string) {
+	if f.continued {
+		f.continued = false
+		s = strings.TrimPrefix(s, " ")
+	}
 	f.b.WriteString(s)
 	if lastNewline := strings.LastIndexByte(s, '\n'); lastNewline >= 0 {
 		f.lineLen = len(s) - lastNewline - 1
@@ -162,6 +168,14 @@ func (f *formatter) writeContinuation() {
 	f.write(strings.Repeat(f.indentStr, f.indentLevel+1))
 }
 
+// writeCommentContinuation is like writeContinuation, but puts the comment c
+// after the escline.
+func (f *formatter) writeCommentContinuation(c string) {
+	f.write(" \\ // " + c + "\n")
+	f.write(strings.Repeat(f.indentStr, f.indentLevel+1))
+	f.continued = true
+}
+
 func (f *formatter) formatDocument(d *Document) {
 	for i, n := range d.Nodes {
 		for j, c := range n.leadingComments {
@@ -173,6 +187,12 @@ func (f *formatter) formatDocument(d *Document) {
 		if (i > 0 || len(n.leadingComments) > 0) && f.preserveBlankLines && n.blankLineBefore {
 			f.write("\n")
 		}
+		if n.comment != "" {
+			for _, line := range commentLines(n.comment) {
+				f.writeIndent()
+				f.write(line + "\n")
+			}
+		}
 		f.formatNode(n)
 	}
 	for j, c := range d.TrailingComments {
@@ -248,9 +268,26 @@ func (f *formatter) formatNodeBody(n *Node, prefix string) {
 		}
 	}
 
+	children := n.Children().Nodes
+	_, hasSourceChildren := n.ChildrenInline()
+	childComments := n.Children().TrailingComments
+	hasChildren := len(children) > 0 || hasSourceChildren || len(childComments) > 0
+
 	plan := f.bodyPlan(n)
+	// isLast reports whether the plan entry at i is the last entry of the
+	// node. endsLine adds that it is the last thing written on the node's
+	// line, so an argument comment there needs no escline; beforeChildren,
+	// that only the children block follows, so the comment can go after the
+	// block's opening brace.
+	isLast := func(i int) bool {
+		return i == len(plan)-1 && len(slashedArgAt[len(n.args)]) == 0 &&
+			len(slashedPropAt[len(entries)]) == 0 && len(slashedChildren) == 0
+	}
+	endsLine := func(i int) bool { return isLast(i) && !hasChildren && n.trailingComment == nil }
+	beforeChildren := func(i int) bool { return isLast(i) && hasChildren }
+	lineComment, openComment := "", ""
 	var argIndex, propIndex int
-	for _, kind := range plan {
+	for i, kind := range plan {
 		switch kind {
 		case nodeEntryArg:
 			writeSlashedArgsAt(argIndex)
@@ -261,6 +298,15 @@ func (f *formatter) formatNodeBody(n *Node, prefix string) {
 				argStr = argStr[1:]
 			}
 			f.write(argStr)
+			if c := n.ArgumentComment(argIndex); c != "" {
+				if endsLine(i) {
+					lineComment = c
+				} else if beforeChildren(i) {
+					openComment = c
+				} else {
+					f.writeCommentContinuation(c)
+				}
+			}
 			argIndex++
 		case nodeEntryProp:
 			writeSlashedPropsAt(propIndex)
@@ -282,11 +328,16 @@ func (f *formatter) formatNodeBody(n *Node, prefix string) {
 		f.writeSlashedChildrenBlock(sd)
 	}
 
-	children := n.Children().Nodes
-	_, hasSourceChildren := n.ChildrenInline()
-	childComments := n.Children().TrailingComments
-	if len(children) > 0 || hasSourceChildren || len(childComments) > 0 {
-		if len(children) == 0 && len(childComments) == 0 {
+	if hasChildren {
+		if openComment != "" {
+			// the comment ends the opening line, so the block is never inline
+			f.write(" { // " + openComment + "\n")
+			f.indentLevel++
+			f.formatDocument(n.Children())
+			f.indentLevel--
+			f.writeIndent()
+			f.write("}")
+		} else if len(children) == 0 && len(childComments) == 0 {
 			f.write(" {}")
 		} else if len(children) == 0 {
 			// comments only
@@ -318,6 +369,8 @@ func (f *formatter) formatNodeBody(n *Node, prefix string) {
 		// trailingComment.text already includes the trailing newline
 		f.write(" ")
 		f.write(n.trailingComment.text)
+	} else if lineComment != "" {
+		f.write(" // " + lineComment + "\n")
 	} else {
 		f.write("\n")
 	}
@@ -389,7 +442,10 @@ func (f *formatter) inlineChildren(nodes []*Node) (string, bool) {
 		if i > 0 && f.preserveBlankLines && c.blankLineBefore {
 			return "", false
 		}
-		if len(c.leadingComments) > 0 || c.trailingComment != nil {
+		if len(c.leadingComments) > 0 || c.comment != "" || c.trailingComment != nil {
+			return "", false
+		}
+		if slices.ContainsFunc(c.argComments, func(s string) bool { return s != "" }) {
 			return "", false
 		}
 		for _, sd := range c.inlineSlashdashes {
@@ -524,17 +580,13 @@ func (f *formatter) valueToString(v Value) string {
 		} else {
 			b.WriteString(f.stringToKDL(v.String()))
 		}
-	case Int:
-		if lit, ok := v.Literal(); ok {
-			b.WriteString(lit)
-		} else {
-			b.WriteString(strconv.FormatInt(int64(v.Int()), 10))
-		}
-	case BigInt:
+	case Int, BigInt:
 		if lit, ok := v.Literal(); ok {
 			b.WriteString(lit)
+		} else if v.src != nil && v.src.intFormatSet {
+			b.WriteString(formatInteger(v, v.src.intFormat))
 		} else {
-			b.WriteString(v.BigInt().String())
+			b.WriteString(formatInteger(v, Decimal))
 		}
 	case Float:
 		if lit, ok := v.Literal(); ok {
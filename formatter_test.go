@@ -795,6 +795,11 @@ func TestFormatMultilineCommentBetweenNodes(t *testing.T) {
 	checkFormat(t, src, "a\n/* mid */\nb\n")
 }
 
+func TestFormatMultilineCommentBetweenArgsDropped(t *testing.T) {
+	src := "node 1 /* mid */ 2 // trailing\n"
+	checkFormat(t, src, "node 1 2 // trailing\n")
+}
+
 func TestFormatMultilineCommentNestedPreserved(t *testing.T) {
 	src := "/* outer /* inner */ outer */\nnode\n"
 	checkFormat(t, src, "/* outer /* inner */ outer */\nnode\n")
@@ -825,6 +830,33 @@ func TestFormatSlashdashNodeWithMultilineChildren(t *testing.T) {
 	checkFormat(t, src, "/- parent {\n\tchild\n}\nother\n")
 }
 
+// A slashdashed node keeps its own slashdashed children, args, and props; they
+// are re-emitted as-is inside the commented-out node.
+func TestFormatSlashdashNodeWithSlashdashedChildren(t *testing.T) {
+	src := "/-parent {\n/-child 1\nkeep /-2 3\n}\nafter\n"
+	checkFormat(t, src, "/- parent {\n\t/- child 1\n\tkeep /-2 3\n}\nafter\n")
+
+	doc := parseDoc(t, src)
+	if len(doc.Nodes) != 1 || doc.Nodes[0].Name() != "after" {
+		t.Fatalf("expected only the 'after' node, got %d nodes", len(doc.Nodes))
+	}
+	comments := doc.Nodes[0].LeadingComments()
+	if len(comments) != 1 || comments[0].Kind() != CommentSlashdash {
+		t.Fatalf("expected one slashdash comment, got %v", comments)
+	}
+	parent := comments[0].SlashedNode()
+	if parent.Name() != "parent" || len(parent.Children().Nodes) != 1 {
+		t.Fatalf("unexpected slashed node: %v", parent)
+	}
+	keep := parent.Children().Nodes[0]
+	if keep.Name() != "keep" || len(keep.Arguments()) != 1 || len(keep.InlineSlashdashes()) != 1 {
+		t.Errorf("unexpected child node: %v", keep)
+	}
+	if inner := keep.LeadingComments(); len(inner) != 1 || inner[0].SlashedNode().Name() != "child" {
+		t.Errorf("expected slashdashed 'child' before 'keep', got %v", inner)
+	}
+}
+
 func TestFormatSlashdashBeforeFirstNode(t *testing.T) {
 	src := "/-disabled\nnode\n"
 	checkFormat(t, src, "/- disabled\nnode\n")
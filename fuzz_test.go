@@ -2,6 +2,7 @@ package kdl_test
 
 import (
 	"io/fs"
+	"math"
 	"strings"
 	"testing"
 
@@ -39,6 +40,56 @@ func FuzzParse(f *testing.F) {
 	})
 }
 
+// FuzzRoundTrip asserts that any input which parses without errors can be
+// emitted, and that the emitted document parses back to an equal document.
+func FuzzRoundTrip(f *testing.F) {
+	seedCorpus(f, test.Kdl1Tests, "kdl1/tests/test_cases")
+	seedCorpus(f, test.Kdl2Tests, "kdl2/tests/test_cases")
+
+	f.Add("node 1 2.5 #true #null key=\"value\"")
+	f.Add("(t)node (u8)1 { child; }")
+	f.Add("node #inf #-inf 0x10 0o7 0b1 1e10")
+	f.Add("node \"\\u{1F600}\\n\" r#\"raw\"#")
+
+	f.Fuzz(func(t *testing.T, src string) {
+		checkRoundTrip(t, src)
+	})
+}
+
+// checkRoundTrip parses src and, if it is valid, checks that emitting and
+// re-parsing the document produces the same document according to [kdl.Diff].
+// NaN values are considered equal to each other.
+func checkRoundTrip(t *testing.T, src string) {
+	t.Helper()
+	doc, err := kdl.ParseString(src)
+	if err != nil {
+		return
+	}
+	out, err := kdl.EmitToString(doc)
+	if err != nil {
+		t.Fatalf("EmitToString failed: %v\ninput:\n%s", err, src)
+	}
+	reparsed, err := kdl.ParseString(out)
+	if err != nil {
+		t.Fatalf("re-parsing emitted document failed: %v\ninput:\n%s\nemitted:\n%s", err, src, out)
+	}
+	changes, err := kdl.Diff(doc, reparsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range changes {
+		if c.Kind == kdl.ChangeModified && isNaN(c.Old) && isNaN(c.New) {
+			continue
+		}
+		t.Errorf("round trip changed %s: %v -> %v\ninput:\n%s\nemitted:\n%s", c.Path, c.Old, c.New, src, out)
+	}
+}
+
+func isNaN(v any) bool {
+	value, ok := v.(kdl.Value)
+	return ok && value.Kind() == kdl.Float && math.IsNaN(value.Float())
+}
+
 func seedCorpus(f *testing.F, fsys fs.ReadDirFS, root string) {
 	f.Helper()
 	_ = fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
@@ -0,0 +1,120 @@
+package kdl
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ResolveIncludes replaces each node named "include" in doc with the nodes of
+// the document it names, as [IncludeResolver.Resolve] does with loader as its
+// Load function. For example, with a loader that opens files,
+//
+//	include "defaults.kdl"
+//	server port=8080
+//
+// resolves to the nodes of defaults.kdl followed by the server node.
+func ResolveIncludes(doc *Document, loader func(path string) (io.Reader, error)) error {
+	return IncludeResolver{Load: loader}.Resolve(doc)
+}
+
+// An IncludeResolver splices included documents into a document after it has
+// been parsed, turning include nodes into the nodes they refer to.
+type IncludeResolver struct {
+	// NodeName is the name of the nodes that include another document. If it
+	// is empty, "include" is used.
+	NodeName string
+
+	// Load opens the document at path. If the returned reader is also an
+	// [io.Closer], it is closed once the document has been parsed.
+	Load func(path string) (io.Reader, error)
+
+	// Path is the path of the document being resolved, if it has one. It is
+	// the start of the include chain, so a document that includes itself
+	// again is reported without being loaded, and relative paths in the
+	// document are taken relative to its directory.
+	Path string
+
+	// ParseOptions are passed to [Parse] for each included document, after
+	// a [WithSourceName] option giving its path.
+	ParseOptions []ParseOption
+}
+
+// Resolve replaces each include node in doc, at any depth, with the top-level
+// nodes of the document it names, in place. An include node must have a single
+// string argument, the path to load, and no properties or children.
+//
+// Included documents are parsed with their path as the source name, and their
+// own include nodes are resolved in turn; a relative path in an included
+// document is taken relative to the directory of that document's path, while
+// paths in doc itself are taken relative to the directory of Path, or passed
+// to Load as written (after [filepath.Clean]) if Path is empty.
+//
+// Resolve returns an error if an include node is malformed, if Load or parsing
+// fails, or if a document includes itself directly or indirectly, in which
+// case the error shows the chain of includes, as in "a.kdl -> b.kdl -> a.kdl".
+// doc may be partially resolved when an error is returned.
+func (r IncludeResolver) Resolve(doc *Document) error {
+	if r.NodeName == "" {
+		r.NodeName = "include"
+	}
+	dir, chain := "", []string(nil)
+	if r.Path != "" {
+		root := filepath.Clean(r.Path)
+		dir, chain = filepath.Dir(root), []string{root}
+	}
+	if err := r.resolve(doc, dir, chain); err != nil {
+		return fmt.Errorf("kdl.ResolveIncludes: %w", err)
+	}
+	return nil
+}
+
+func (r IncludeResolver) resolve(doc *Document, dir string, chain []string) error {
+	nodes := make([]*Node, 0, len(doc.Nodes))
+	for _, n := range doc.Nodes {
+		if n.name != r.NodeName {
+			if err := r.resolve(&n.children, dir, chain); err != nil {
+				return err
+			}
+			nodes = append(nodes, n)
+			continue
+		}
+
+		if len(n.args) != 1 || n.args[0].kind != String || len(n.props) > 0 || len(n.children.Nodes) > 0 {
+			return fmt.Errorf("%s: %s node must have a single string argument and nothing else", n.loc, r.NodeName)
+		}
+		path := n.args[0].raw.(string)
+		if dir != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		path = filepath.Clean(path)
+		if slices.Contains(chain, path) {
+			return fmt.Errorf("%s: include cycle: %s -> %s", n.loc, strings.Join(chain, " -> "), path)
+		}
+
+		included, err := r.load(path)
+		if err != nil {
+			return fmt.Errorf("%s: including %q: %w", n.loc, path, err)
+		}
+		if err := r.resolve(included, filepath.Dir(path), append(slices.Clip(chain), path)); err != nil {
+			return err
+		}
+		nodes = append(nodes, included.Nodes...)
+	}
+	doc.Nodes = nodes
+	doc.adopt(nodes...)
+	return nil
+}
+
+func (r IncludeResolver) load(path string) (*Document, error) {
+	rd, err := r.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := rd.(io.Closer); ok {
+		defer c.Close()
+	}
+	return Parse(rd, append([]ParseOption{WithSourceName(path)}, r.ParseOptions...)...)
+}
@@ -0,0 +1,133 @@
+package kdl_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/calico32/kdl-go"
+)
+
+func TestResolveIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defaults.kdl":      {Data: []byte("log-level info\ninclude \"conf/db.kdl\"\n")},
+		"conf/db.kdl":       {Data: []byte("database host=localhost\ninclude \"pool.kdl\"\n")},
+		"conf/pool.kdl":     {Data: []byte("pool size=4\n")},
+		"routes.kdl":        {Data: []byte("route \"/\"\n")},
+		"cycle/a.kdl":       {Data: []byte("a\ninclude \"b.kdl\"\n")},
+		"cycle/b.kdl":       {Data: []byte("b\ninclude \"a.kdl\"\n")},
+		"bad/syntax.kdl":    {Data: []byte("node {\n")},
+		"bad/malformed.kdl": {Data: []byte("include 1\n")},
+	}
+	var opened []string
+	loader := func(path string) (io.Reader, error) {
+		path = filepath.ToSlash(path)
+		opened = append(opened, path)
+		return fsys.Open(path)
+	}
+
+	doc, err := kdl.ParseString(`
+include "defaults.kdl"
+server port=8080 {
+    include "routes.kdl"
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kdl.ResolveIncludes(doc, loader); err != nil {
+		t.Fatalf("ResolveIncludes() error = %v", err)
+	}
+	const want = `log-level info
+database host=localhost
+pool size=4
+server port=8080 {
+    route "/"
+}
+`
+	if got := doc.String(); got != want {
+		t.Errorf("ResolveIncludes() =\n%s\nwant:\n%s", got, want)
+	}
+	if want := "defaults.kdl conf/db.kdl conf/pool.kdl routes.kdl"; strings.Join(opened, " ") != want {
+		t.Errorf("opened %v, want %s", opened, want)
+	}
+	if route := doc.Nodes[3].GetChild("route"); route.Parent() != doc.Nodes[3] {
+		t.Errorf("included route has parent %v, want server", route.Parent())
+	}
+	if loc := doc.Nodes[2].Location(); loc.Filename != "conf/pool.kdl" || loc.Line != 1 {
+		t.Errorf("included node location = %v, want conf/pool.kdl:1:1", loc)
+	}
+
+	// a custom node name
+	doc, err = kdl.ParseString(`import "routes.kdl"; include "ignored.kdl"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (kdl.IncludeResolver{NodeName: "import", Load: loader}).Resolve(doc); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if got := doc.String(); got != "route \"/\"\ninclude ignored.kdl\n" {
+		t.Errorf("Resolve() = %q", got)
+	}
+
+	errTests := []struct {
+		src, want string
+	}{
+		{`include "cycle/a.kdl"`, `include cycle: cycle/a.kdl -> cycle/b.kdl -> cycle/a.kdl`},
+		{`include "missing.kdl"`, `<input>:1:1: including "missing.kdl": open missing.kdl: file does not exist`},
+		{`include "bad/syntax.kdl"`, `including "bad/syntax.kdl": `},
+		{`include "bad/malformed.kdl"`, `bad/malformed.kdl:1:1: include node must have a single string argument`},
+		{`include "a.kdl" "b.kdl"`, `include node must have a single string argument`},
+		{`include "a.kdl" { x; }`, `include node must have a single string argument`},
+	}
+	for _, tt := range errTests {
+		doc, err := kdl.ParseString(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = kdl.ResolveIncludes(doc, loader)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ResolveIncludes(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+
+	// with the root's path, a cycle back to it is caught before loading it
+	// again, and the chain starts at the root
+	opened = nil
+	root, err := kdl.ParseString(`include "b.kdl"`, kdl.WithSourceName("cycle/a.kdl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = (kdl.IncludeResolver{Load: loader, Path: "cycle/a.kdl"}).Resolve(root)
+	if err == nil || !strings.Contains(err.Error(), "include cycle: cycle/a.kdl -> cycle/b.kdl -> cycle/a.kdl") {
+		t.Errorf("Resolve() error = %v, want a cycle from cycle/a.kdl", err)
+	}
+	if want := "cycle/b.kdl"; strings.Join(opened, " ") != want {
+		t.Errorf("opened %v, want %s", opened, want)
+	}
+
+	// parse options apply to included documents
+	v1 := fstest.MapFS{"v1.kdl": {Data: []byte("node true\n")}}
+	v1Loader := func(path string) (io.Reader, error) { return v1.Open(filepath.ToSlash(path)) }
+	doc, _ = kdl.ParseString(`include "v1.kdl"`)
+	if err := kdl.ResolveIncludes(doc, v1Loader); err != nil {
+		t.Fatalf("ResolveIncludes() of a KDL v1 file error = %v", err)
+	}
+	if v := doc.Nodes[0].Arg(0); v.Kind() != kdl.Bool || !v.Bool() {
+		t.Errorf("included v1 argument = %v, want #true", v)
+	}
+	doc, _ = kdl.ParseString(`include "v1.kdl"`)
+	err = (kdl.IncludeResolver{Load: v1Loader, ParseOptions: []kdl.ParseOption{kdl.WithVersion(kdl.Version2)}}).Resolve(doc)
+	if err == nil || !strings.Contains(err.Error(), `including "v1.kdl"`) {
+		t.Errorf("Resolve() of a KDL v1 file with WithVersion(Version2) error = %v, want a parse error", err)
+	}
+
+	doc, _ = kdl.ParseString(`include "missing.kdl"`)
+	if err := kdl.ResolveIncludes(doc, loader); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ResolveIncludes() error = %v, want fs.ErrNotExist", err)
+	}
+}
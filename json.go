@@ -1,101 +1,342 @@
-// JSON serialization for KDL documents, nodes, and values, in the format that
-// kdl-test expects. Not intended for general use, just for testing.
+// JSON conversion for KDL documents, nodes, and values.
 
 package kdl
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"math"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
 // MarshalJSON implements [json.Marshaler] for Document, encoding the document
-// as a JSON array of nodes, matching the expectations of kdl-test. The JSON
-// format is not intended to be stable or for general use.
+// as a JSON array of nodes; see [Node.MarshalJSON] for the format of each node.
+// Comments and other formatting details are not included.
 func (d Document) MarshalJSON() ([]byte, error) {
-	nodes := d.Nodes
-	if nodes == nil {
-		nodes = []*Node{}
+	var buf bytes.Buffer
+	if err := writeJSONNodes(&buf, d.Nodes); err != nil {
+		return nil, err
 	}
-	return json.Marshal(nodes)
+	return buf.Bytes(), nil
 }
 
 // MarshalJSON implements [json.Marshaler] for Node, encoding the node as a JSON
-// object with "name", "type", "args", "props", and "children" fields, matching
-// the expectations of kdl-test. The JSON format is not intended to be stable or
-// for general use.
+// object of the form:
+//
+//	{"name": "node", "type": "t", "values": [...], "properties": {...}, "children": [...]}
+//
+// The "type" key is omitted if the node has no type annotation. Properties are
+// encoded in [Node.PropertyOrder], and all other keys are always present.
+//
+// Arguments and property values are encoded as JSON scalars as described in
+// [Value.MarshalJSON], except that a value with a type annotation is encoded as
+// an object {"type": "t", "value": ...} holding the annotation and the scalar.
 func (n *Node) MarshalJSON() ([]byte, error) {
-	j := map[string]any{
-		"name":     n.name,
-		"type":     nil,
-		"args":     []Value{},
-		"props":    n.props,
-		"children": n.children,
+	var buf bytes.Buffer
+	if err := writeJSONNode(&buf, n); err != nil {
+		return nil, err
 	}
-	if n.typeValid {
-		j["type"] = &n.typ
-	}
-	if len(n.args) > 0 {
-		j["args"] = n.args
-	}
-	return json.Marshal(j)
+	return buf.Bytes(), nil
 }
 
-// MarshalJSON implements [json.Marshaler] for Value, encoding the value as a
-// JSON object with "type" and "value" fields, matching the expectations of
-// kdl-test. The JSON format is not intended to be stable or for general use.
+// MarshalJSON implements [json.Marshaler] for Value, encoding the value as the
+// corresponding JSON scalar: a string, number, boolean, or null. Integers and
+// big numbers are encoded without loss of precision, and floats always include
+// a decimal point or exponent. NaN and infinite floats cannot be represented in
+// JSON and result in an error. Type annotations are not included; use
+// [MarshalValueJSON] for more control over the encoding.
 func (v Value) MarshalJSON() ([]byte, error) {
-	var val map[string]string
-	switch v.Kind() {
+	return marshalValueJSON(v, jsonOptions{})
+}
+
+// MarshalValueJSON is like [Value.MarshalJSON], but accepts [JSONOption]s to
+// customize the encoding.
+func MarshalValueJSON(v Value, opts ...JSONOption) ([]byte, error) {
+	var o jsonOptions
+	for _, opt := range opts {
+		opt.applyJSON(&o)
+	}
+	return marshalValueJSON(v, o)
+}
+
+func marshalValueJSON(v Value, o jsonOptions) ([]byte, error) {
+	if o.typeAnnotations && v.typeValid {
+		var buf bytes.Buffer
+		o.typeAnnotations = false
+		if err := writeJSONValue(&buf, v, o); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var s string
+	switch v.kind {
 	case String:
-		val = map[string]string{"type": "string", "value": v.String()}
+		return json.Marshal(v.raw.(string))
 	case Bool:
-		str := "false"
-		if v.Bool() {
-			str = "true"
-		}
-		val = map[string]string{"type": "boolean", "value": str}
+		return strconv.AppendBool(nil, v.raw.(bool)), nil
 	case Null:
-		val = map[string]string{"type": "null"}
+		return []byte("null"), nil
 	case Int:
-		val = map[string]string{"type": "number", "value": fmt.Sprintf("%d.0", v.Int())}
+		return strconv.AppendInt(nil, int64(v.raw.(int)), 10), nil
+	case BigInt:
+		s = v.raw.(*big.Int).String()
 	case Float:
-		str := fmt.Sprintf("%f", v.Float())
-		if math.IsInf(v.Float(), 1) {
-			str = "inf"
-		} else if math.IsInf(v.Float(), -1) {
-			str = "-inf"
-		} else if math.IsNaN(v.Float()) {
-			str = "nan"
-		} else {
-			// remove extra trailing zeros (e.g. "1.000" -> "1.0")
-			str = strings.TrimRight(str, "0")
-			if str[len(str)-1] == '.' {
-				str += "0"
-			}
+		f := v.raw.(float64)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("kdl: cannot encode %v as JSON", f)
 		}
-		val = map[string]string{"type": "number", "value": str}
-	case BigInt:
-		val = map[string]string{"type": "number", "value": fmt.Sprintf("%d.0", v.BigInt())}
+		return []byte(jsonFloat(strconv.FormatFloat(f, 'g', -1, 64))), nil
 	case BigFloat:
-		str := v.BigFloat().Text('f', -1)
-		// add ".0" if needed
-		if !strings.ContainsAny(str, ".") {
-			str += ".0"
+		f := v.raw.(*big.Float)
+		if f.IsInf() {
+			return nil, fmt.Errorf("kdl: cannot encode %v as JSON", f)
 		}
-		val = map[string]string{"type": "number", "value": str}
+		s = jsonFloat(f.Text('g', -1))
 	default:
-		return nil, nil
+		return nil, fmt.Errorf("kdl: cannot encode %s value as JSON", v.kind)
+	}
+	if o.bigAsString {
+		return json.Marshal(s)
+	}
+	return []byte(s), nil
+}
+
+// jsonFloat normalizes a formatted float for JSON, making sure it reads back as
+// a float rather than an integer.
+func jsonFloat(s string) string {
+	s = strings.Replace(s, "e+", "e", 1)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func writeJSONNodes(buf *bytes.Buffer, nodes []*Node) error {
+	buf.WriteByte('[')
+	for i, n := range nodes {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeJSONNode(buf, n); err != nil {
+			return err
+		}
 	}
+	buf.WriteByte(']')
+	return nil
+}
 
-	var typ *string
-	if v.typeValid {
-		typ = &v.typ
+func writeJSONNode(buf *bytes.Buffer, n *Node) error {
+	buf.WriteString(`{"name":`)
+	writeJSONString(buf, n.name)
+	if n.typeValid {
+		buf.WriteString(`,"type":`)
+		writeJSONString(buf, n.typ)
 	}
 
-	return json.Marshal(map[string]any{
-		"type":  typ,
-		"value": val,
-	})
+	buf.WriteString(`,"values":[`)
+	for i, v := range n.args {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeJSONValue(buf, v, jsonOptions{}); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteString(`],"properties":{`)
+	for i, key := range n.propOrder {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, key)
+		buf.WriteByte(':')
+		if err := writeJSONValue(buf, n.props[key], jsonOptions{}); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteString(`},"children":`)
+	if err := writeJSONNodes(buf, n.children.Nodes); err != nil {
+		return err
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeJSONValue(buf *bytes.Buffer, v Value, o jsonOptions) error {
+	b, err := marshalValueJSON(v, o)
+	if err != nil {
+		return err
+	}
+	if !v.typeValid {
+		buf.Write(b)
+		return nil
+	}
+	buf.WriteString(`{"type":`)
+	writeJSONString(buf, v.typ)
+	buf.WriteString(`,"value":`)
+	buf.Write(b)
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s) // never fails for strings
+	buf.Write(b)
+}
+
+// DocumentFromJSON decodes the JSON representation of a document produced by
+// [Document.MarshalJSON] and returns the corresponding Document. Numbers are
+// decoded as an [Int] or [Float] when they fit, and as a [BigInt] or
+// [BigFloat] otherwise, so no precision is lost; numbers with a decimal point
+// or exponent are always floats. Property order is preserved.
+func DocumentFromJSON(data []byte) (*Document, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("kdl: invalid JSON document: %w", err)
+	}
+	nodes, err := nodesFromJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("kdl: invalid JSON document: %w", err)
+	}
+	return NewDocument(nodes...), nil
+}
+
+type jsonNode struct {
+	Name       *string           `json:"name"`
+	Type       *string           `json:"type"`
+	Values     []json.RawMessage `json:"values"`
+	Properties json.RawMessage   `json:"properties"`
+	Children   []json.RawMessage `json:"children"`
+}
+
+func nodesFromJSON(raw []json.RawMessage) ([]*Node, error) {
+	nodes := make([]*Node, 0, len(raw))
+	for _, r := range raw {
+		n, err := nodeFromJSON(r)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+func nodeFromJSON(data []byte) (*Node, error) {
+	var jn jsonNode
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&jn); err != nil {
+		return nil, err
+	}
+	if jn.Name == nil {
+		return nil, fmt.Errorf("node is missing a name")
+	}
+
+	n := NewNode(*jn.Name)
+	if jn.Type != nil {
+		n.typ, n.typeValid = *jn.Type, true
+	}
+	for _, r := range jn.Values {
+		v, err := valueFromJSON(r)
+		if err != nil {
+			return nil, fmt.Errorf("node %q: %w", n.name, err)
+		}
+		n.AddArgument(v)
+	}
+	if len(jn.Properties) > 0 && !bytes.Equal(jn.Properties, []byte("null")) {
+		err := decodeJSONObject(jn.Properties, func(key string, r json.RawMessage) error {
+			v, err := valueFromJSON(r)
+			if err != nil {
+				return fmt.Errorf("property %q: %w", key, err)
+			}
+			n.AddProperty(key, v)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("node %q: %w", n.name, err)
+		}
+	}
+	children, err := nodesFromJSON(jn.Children)
+	if err != nil {
+		return nil, err
+	}
+	n.AddChildren(children...)
+	return n, nil
+}
+
+// decodeJSONObject calls fn for each member of the JSON object in data, in
+// order.
+func decodeJSONObject(data []byte, fn func(key string, value json.RawMessage) error) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("properties must be an object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		if err := fn(tok.(string), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func valueFromJSON(data []byte) (Value, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var annotated struct {
+			Type  *string         `json:"type"`
+			Value json.RawMessage `json:"value"`
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&annotated); err != nil {
+			return Value{}, err
+		}
+		if annotated.Type == nil || annotated.Value == nil {
+			return Value{}, fmt.Errorf("annotated value must have \"type\" and \"value\" keys")
+		}
+		if v := bytes.TrimSpace(annotated.Value); len(v) > 0 && v[0] == '{' {
+			return Value{}, fmt.Errorf("annotated value must be a scalar")
+		}
+		v, err := valueFromJSON(annotated.Value)
+		if err != nil {
+			return Value{}, err
+		}
+		return v.WithTypeAnnotation(*annotated.Type, true), nil
+	}
+
+	var scalar any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&scalar); err != nil {
+		return Value{}, err
+	}
+	switch s := scalar.(type) {
+	case nil:
+		return NewNull(), nil
+	case bool:
+		return NewBool(s), nil
+	case string:
+		return NewString(s), nil
+	case json.Number:
+		v, ok := newNumber(s.String(), 10, strings.ContainsAny(s.String(), ".eE"))
+		if !ok {
+			return Value{}, fmt.Errorf("invalid number %s", s)
+		}
+		return v, nil
+	default:
+		return Value{}, fmt.Errorf("unsupported JSON value %s", data)
+	}
 }
@@ -0,0 +1,30 @@
+package kdl
+
+// A JSONOption configures the behavior of [MarshalValueJSON]. See the various
+// WithJSON* functions for specific options.
+type JSONOption interface {
+	applyJSON(*jsonOptions)
+}
+
+type jsonOptionFunc func(*jsonOptions)
+
+func (fn jsonOptionFunc) applyJSON(o *jsonOptions) { fn(o) }
+
+type jsonOptions struct {
+	bigAsString     bool
+	typeAnnotations bool
+}
+
+// WithJSONBigAsString controls whether [BigInt] and [BigFloat] values are
+// encoded as JSON strings instead of numbers, for consumers that would
+// otherwise lose precision (such as JavaScript). Default: false.
+func WithJSONBigAsString(v bool) JSONOption {
+	return jsonOptionFunc(func(o *jsonOptions) { o.bigAsString = v })
+}
+
+// WithJSONTypeAnnotations controls whether a value with a type annotation is
+// encoded as an object {"type": "t", "value": ...}, as in [Node.MarshalJSON],
+// instead of a bare scalar. Default: false.
+func WithJSONTypeAnnotations(v bool) JSONOption {
+	return jsonOptionFunc(func(o *jsonOptions) { o.typeAnnotations = v })
+}
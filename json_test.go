@@ -0,0 +1,160 @@
+package kdl_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/calico32/kdl-go"
+)
+
+func TestDocumentMarshalJSON(t *testing.T) {
+	doc, err := kdl.ParseString(`
+// comments are dropped
+(cfg)server "main" 8080 enabled=#true ratio=0.5 {
+    listen (ip)"127.0.0.1" port=(u16)80
+    big 123456789012345678901234567890 1.5e30
+    empty #null
+}
+leaf
+`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	const want = `[` +
+		`{"name":"server","type":"cfg","values":["main",8080],"properties":{"enabled":true,"ratio":0.5},"children":[` +
+		`{"name":"listen","values":[{"type":"ip","value":"127.0.0.1"}],"properties":{"port":{"type":"u16","value":80}},"children":[]},` +
+		`{"name":"big","values":[123456789012345678901234567890,1.5e30],"properties":{},"children":[]},` +
+		`{"name":"empty","values":[null],"properties":{},"children":[]}` +
+		`]},` +
+		`{"name":"leaf","values":[],"properties":{},"children":[]}` +
+		`]`
+
+	got, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal error = %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("json.Marshal() =\n%s\nwant\n%s", got, want)
+	}
+
+	empty, err := json.Marshal(kdl.NewDocument())
+	if err != nil || string(empty) != "[]" {
+		t.Errorf("json.Marshal(empty) = %s, %v, want []", empty, err)
+	}
+}
+
+func TestDocumentMarshalJSONNaN(t *testing.T) {
+	doc := kdl.NewDocument(kdl.NewNode("n").AddArgument(kdl.NewFloat(math.NaN())))
+	if _, err := json.Marshal(doc); err == nil {
+		t.Error("expected an error encoding NaN")
+	}
+}
+
+func TestMarshalValueJSON(t *testing.T) {
+	bi, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		value kdl.Value
+		opts  []kdl.JSONOption
+		want  string
+	}{
+		{kdl.NewNull(), nil, `null`},
+		{kdl.NewBool(false), nil, `false`},
+		{kdl.NewString("a\"b"), nil, `"a\"b"`},
+		{kdl.NewInt(-42), nil, `-42`},
+		{kdl.NewFloat(2), nil, `2.0`},
+		{kdl.NewBigInt(bi), nil, `123456789012345678901234567890`},
+		{kdl.NewBigInt(bi), []kdl.JSONOption{kdl.WithJSONBigAsString(true)}, `"123456789012345678901234567890"`},
+		{kdl.NewInt(42), []kdl.JSONOption{kdl.WithJSONBigAsString(true)}, `42`},
+		{kdl.NewInt(80).WithTypeAnnotation("u16", true), nil, `80`},
+		{kdl.NewInt(80).WithTypeAnnotation("u16", true), []kdl.JSONOption{kdl.WithJSONTypeAnnotations(true)}, `{"type":"u16","value":80}`},
+		{kdl.NewString("x"), []kdl.JSONOption{kdl.WithJSONTypeAnnotations(true)}, `"x"`},
+		{
+			kdl.NewBigInt(bi).WithTypeAnnotation("i128", true),
+			[]kdl.JSONOption{kdl.WithJSONTypeAnnotations(true), kdl.WithJSONBigAsString(true)},
+			`{"type":"i128","value":"123456789012345678901234567890"}`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := kdl.MarshalValueJSON(tt.value, tt.opts...)
+		if err != nil {
+			t.Errorf("MarshalValueJSON(%v) error = %v", tt.value, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("MarshalValueJSON(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	got, err := json.Marshal(kdl.NewNull())
+	if err != nil || string(got) != "null" {
+		t.Errorf("json.Marshal(null) = %s, %v, want null", got, err)
+	}
+}
+
+func TestDocumentFromJSON(t *testing.T) {
+	srcs := []string{
+		"",
+		"node",
+		`(cfg)server "main" 8080 enabled=#true ratio=0.5 z=1 a=2 {
+    listen (ip)"127.0.0.1" port=(u16)80
+    big 123456789012345678901234567890 1.5e30 0.1 -7 1.0
+    empty #null "" ""
+}
+leaf`,
+		`"node with spaces" "string with \"quotes\"" key="<&>"`,
+	}
+	for _, src := range srcs {
+		doc, err := kdl.ParseString(src)
+		if err != nil {
+			t.Fatalf("parse %q: %v", src, err)
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatalf("json.Marshal(%q) error = %v", src, err)
+		}
+		got, err := kdl.DocumentFromJSON(data)
+		if err != nil {
+			t.Fatalf("DocumentFromJSON(%s) error = %v", data, err)
+		}
+		if !got.EqualOrdered(doc) {
+			want, _ := kdl.EmitToString(doc)
+			gotStr, _ := kdl.EmitToString(got)
+			t.Errorf("round trip of %q via %s =\n%s\nwant\n%s", src, data, gotStr, want)
+		}
+	}
+
+	doc, err := kdl.DocumentFromJSON([]byte(`[{"name":"n","values":[1,1.0,99999999999999999999,{"type":"t","value":"x"}],"properties":{"b":true,"a":null}}]`))
+	if err != nil {
+		t.Fatalf("DocumentFromJSON() error = %v", err)
+	}
+	n := doc.Nodes[0]
+	kinds := []kdl.ValueKind{kdl.Int, kdl.Float, kdl.BigInt, kdl.String}
+	for i, want := range kinds {
+		if got := n.Arg(i).Kind(); got != want {
+			t.Errorf("argument %d kind = %s, want %s", i, got, want)
+		}
+	}
+	if ty, ok := n.Arg(3).TypeAnnotation(); !ok || ty != "t" {
+		t.Errorf("argument 3 type annotation = %q, %v, want t", ty, ok)
+	}
+	if order := n.PropertyOrder(); len(order) != 2 || order[0] != "b" || order[1] != "a" {
+		t.Errorf("property order = %v, want [b a]", order)
+	}
+
+	for _, bad := range []string{
+		`{}`,
+		`[{"values":[]}]`,
+		`[{"name":"n","extra":1}]`,
+		`[{"name":"n","values":[[1]]}]`,
+		`[{"name":"n","values":[{"value":1}]}]`,
+		`[{"name":"n","values":[{"type":"t","value":{"type":"u","value":1}}]}]`,
+		`[{"name":"n","properties":[]}]`,
+	} {
+		if _, err := kdl.DocumentFromJSON([]byte(bad)); err == nil {
+			t.Errorf("DocumentFromJSON(%s) returned no error", bad)
+		}
+	}
+}
@@ -22,8 +22,10 @@
 // functions and methods to create KDL documents, nodes, key-value nodes, and
 // values.
 //
-// Other features include AST traversal via [Walk], and KDL Schema validation
-// via [ParseSchema] and [ValidateDocument].
+// Other features include AST traversal via [Walk], KQL-style node queries via
+// [Query], include resolution via [ResolveIncludes], and KDL Schema validation
+// via [ParseSchema] (or [LoadSchema]) and [Schema.Validate] or
+// [ValidateDocument].
 //
 // [KDL]: https://kdl.dev/
 package kdl
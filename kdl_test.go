@@ -3,10 +3,12 @@ package kdl_test
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -81,6 +83,7 @@ func testKdlSuite(version kdl.Version, fs filesys, dir string, t *testing.T) {
 
 			opts := []kdl.EmitOption{
 				kdl.WithTestSuiteFloatOptions(),
+				kdl.WithSortProperties(true),
 				kdl.WithVersion(version),
 			}
 			opts = append(opts, testSpecificEmitterOptions(caseFile.Name(), version)...)
@@ -235,6 +238,33 @@ func TestValueEndLocation(t *testing.T) {
 	}
 }
 
+func TestSpan(t *testing.T) {
+	src := "first\nnode 1 \"two\" k=3 {\n    child\n}\n"
+	doc, err := kdl.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	node := doc.Nodes[1]
+	start := strings.Index(src, "node")
+	if got, want := node.Span(), (kdl.Span{Start: kdl.Pos(start), End: kdl.Pos(len(src) - 1)}); got != want {
+		t.Errorf("Node.Span() = %+v, want %+v", got, want)
+	}
+	if got := src[node.Span().Start:node.Span().End]; got != "node 1 \"two\" k=3 {\n    child\n}" {
+		t.Errorf("node span text = %q", got)
+	}
+	if got := node.Arg(1).Span(); src[got.Start:got.End] != `"two"` {
+		t.Errorf("value span text = %q, want %q", src[got.Start:got.End], `"two"`)
+	}
+
+	doc, err = kdl.Parse(strings.NewReader(src), kdl.WithLocations(false))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := doc.Nodes[1].Span(); got != (kdl.Span{}) {
+		t.Errorf("Node.Span() without locations = %+v, want zero", got)
+	}
+}
+
 // TestFormatRoundtrip asserts that for every parseable input in the v2
 // conformance suite, Parse -> Format -> Parse yields a document that is
 // canonically equivalent to the original.
@@ -308,3 +338,521 @@ func timeout[T any](duration time.Duration, f func() (T, error)) (result T, err
 		return zero, errors.New("operation timed out")
 	}
 }
+
+func TestNodeAnnotationBuilders(t *testing.T) {
+	doc := kdl.NewDocument(
+		kdl.NewNode("person").
+			WithTypeAnnotation("author").
+			WithArgumentAnnotated("date", "2020-01-01").
+			WithArgumentAnnotated("u8", kdl.NewInt(5)).
+			AddProperty("id", kdl.NewString("x").Annotated("uuid")),
+	)
+	got, err := kdl.EmitToString(doc)
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if want := "(author)person (date)\"2020-01-01\" (u8)5 id=(uuid)x\n"; got != want {
+		t.Errorf("Emit() = %q, want %q", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("WithArgumentAnnotated with an unsupported type did not panic")
+		}
+	}()
+	kdl.NewNode("n").WithArgumentAnnotated("t", struct{}{})
+}
+
+func TestNodeRemove(t *testing.T) {
+	doc, err := kdl.ParseString(`server "a" "b" "c" x=1 y=2 z=3 {
+    listen 80
+    route "/"
+    listen 443
+    route "/api"
+    listen 8080
+}`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	n := doc.Nodes[0]
+
+	if !n.RemoveChild("route") {
+		t.Error("RemoveChild(route) = false, want true")
+	}
+	if n.RemoveChild("missing") {
+		t.Error("RemoveChild(missing) = true, want false")
+	}
+	if got := n.RemoveChildren("listen"); got != 3 {
+		t.Errorf("RemoveChildren(listen) = %d, want 3", got)
+	}
+	if got := n.RemoveChildren("listen"); got != 0 {
+		t.Errorf("RemoveChildren(listen) again = %d, want 0", got)
+	}
+
+	n.RemoveArgument(1).RemoveProperty("y")
+	if got := n.Arg(1).String(); got != "c" {
+		t.Errorf("Arg(1) after RemoveArgument = %q, want c", got)
+	}
+	if _, ok := n.Properties()["y"]; ok || len(n.PropertyOrder()) != 2 {
+		t.Errorf("after RemoveProperty: Properties = %v, PropertyOrder = %v", n.Properties(), n.PropertyOrder())
+	}
+
+	got, err := kdl.EmitToString(doc)
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if want := "server a c x=1 z=3 {\n    route \"/api\"\n}\n"; got != want {
+		t.Errorf("Emit() = %q, want %q", got, want)
+	}
+}
+
+func TestNodeParent(t *testing.T) {
+	root := kdl.NewNode("root")
+	a := kdl.NewNode("a")
+	b := kdl.NewNode("b")
+	c := kdl.NewNode("c")
+	root.AddChild(a).AddChildren(b)
+	if err := root.InsertChild(0, c); err != nil {
+		t.Fatal(err)
+	}
+	kv := root.NewChild("child").AddKV("key", kdl.NewInt(1)).Children().Nodes[0]
+	root.Children().AddNode(kdl.NewNode("d"))
+
+	for _, child := range root.Children().Nodes {
+		if child.Parent() != root {
+			t.Errorf("%s.Parent() = %v, want root", child.Name(), child.Parent())
+		}
+	}
+	if kv.Parent() == nil || kv.Parent().Name() != "child" {
+		t.Errorf("key.Parent() = %v, want child", kv.Parent())
+	}
+	if root.Parent() != nil {
+		t.Errorf("root.Parent() = %v, want nil", root.Parent())
+	}
+
+	root.RemoveChild("a")
+	if a.Parent() != nil {
+		t.Error("a.Parent() is set after RemoveChild")
+	}
+
+	doc, err := kdl.ParseString("top { mid { leaf; }; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := doc.Nodes[0]
+	mid := top.Children().Nodes[0]
+	leaf := mid.Children().Nodes[0]
+	if top.Parent() != nil || mid.Parent() != top || leaf.Parent() != mid {
+		t.Errorf("parsed parents = %v, %v, %v; want nil, top, mid", top.Parent(), mid.Parent(), leaf.Parent())
+	}
+
+	clone := top.Clone()
+	if clone.Parent() != nil || clone.Children().Nodes[0].Parent() != clone {
+		t.Error("cloned children do not point at the clone")
+	}
+	// NewDocument detaches its nodes from any previous parent
+	p := kdl.NewNode("p")
+	pc := kdl.NewNode("c")
+	p.AddChild(pc)
+	kdl.NewDocument(pc)
+	if pc.Parent() != nil {
+		t.Errorf("after NewDocument, Parent() = %v, want nil", pc.Parent())
+	}
+}
+
+func TestNodeAncestors(t *testing.T) {
+	doc, err := kdl.ParseString("server { database { host localhost; }; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := doc.Nodes[0]
+	database := server.GetChild("database")
+	host := database.GetChild("host")
+
+	if got := host.Ancestors(); len(got) != 2 || got[0] != database || got[1] != server {
+		t.Errorf("host.Ancestors() = %v, want [database server]", got)
+	}
+	if got := server.Ancestors(); got == nil || len(got) != 0 {
+		t.Errorf("server.Ancestors() = %#v, want empty slice", got)
+	}
+
+	tests := []struct {
+		node *kdl.Node
+		want string
+	}{
+		{server, "server"},
+		{database, "server/database"},
+		{host, "server/database/host"},
+		{kdl.NewNode("loose"), "loose"},
+	}
+	for _, tt := range tests {
+		if got := tt.node.Path(); got != tt.want {
+			t.Errorf("Path() = %q, want %q", got, tt.want)
+		}
+	}
+	if n, err := doc.At(host.Path()); err != nil || n != host {
+		t.Errorf("At(%q) = %v, %v; want host", host.Path(), n, err)
+	}
+}
+
+func TestNodeGetArgumentProperty(t *testing.T) {
+	doc, err := kdl.ParseString("server 8080 #null name=main empty=#null { port 1; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := doc.Nodes[0]
+
+	if v, ok := n.GetArgument(0); !ok || v.Int() != 8080 {
+		t.Errorf("GetArgument(0) = %v, %v; want 8080, true", v, ok)
+	}
+	if v, ok := n.GetArgument(1); !ok || v.Kind() != kdl.Null {
+		t.Errorf("GetArgument(1) = %v, %v; want null, true", v, ok)
+	}
+	for _, i := range []int{-1, 2} {
+		if v, ok := n.GetArgument(i); ok || v.IsValid() {
+			t.Errorf("GetArgument(%d) = %v, %v; want zero Value, false", i, v, ok)
+		}
+	}
+
+	if v, ok := n.GetProperty("name"); !ok || v.String() != "main" {
+		t.Errorf("GetProperty(name) = %v, %v; want main, true", v, ok)
+	}
+	if v, ok := n.GetProperty("empty"); !ok || v.Kind() != kdl.Null {
+		t.Errorf("GetProperty(empty) = %v, %v; want null, true", v, ok)
+	}
+	if v, ok := n.GetProperty("port"); ok || v.IsValid() {
+		t.Errorf("GetProperty(port) = %v, %v; want zero Value, false", v, ok)
+	}
+}
+
+func TestNodeHasPredicates(t *testing.T) {
+	doc, err := kdl.ParseString("server 8080 #null name=main empty=#null { port 1; tls { cert a; }; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := doc.Nodes[0]
+
+	for i, want := range map[int]bool{-1: false, 0: true, 1: true, 2: false} {
+		if got := n.HasArgumentAt(i); got != want {
+			t.Errorf("HasArgumentAt(%d) = %v, want %v", i, got, want)
+		}
+	}
+	for key, want := range map[string]bool{"name": true, "empty": true, "port": false} {
+		if got := n.HasProperty(key); got != want {
+			t.Errorf("HasProperty(%q) = %v, want %v", key, got, want)
+		}
+	}
+	for name, want := range map[string]bool{"port": true, "tls": true, "cert": false, "name": false} {
+		if got := n.HasChild(name); got != want {
+			t.Errorf("HasChild(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestNodeCounts(t *testing.T) {
+	doc, err := kdl.ParseString(`server 1 2 3 { route a; listen 80; route b; route c { route d; }; "" 5; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := doc.Nodes[0]
+
+	if got := n.ArgumentCount(); got != 3 {
+		t.Errorf("ArgumentCount() = %d, want 3", got)
+	}
+	for name, want := range map[string]int{"route": 3, "listen": 1, "missing": 0, "": 1} {
+		if got := n.CountChildren(name); got != want {
+			t.Errorf("CountChildren(%q) = %d, want %d", name, got, want)
+		}
+	}
+	if got := n.NumChildren(); got != 5 {
+		t.Errorf("NumChildren() = %d, want 5", got)
+	}
+
+	empty := kdl.NewNode("empty")
+	if got := empty.ArgumentCount(); got != 0 {
+		t.Errorf("ArgumentCount() = %d, want 0", got)
+	}
+	if got := empty.NumChildren(); got != 0 {
+		t.Errorf("NumChildren() = %d, want 0", got)
+	}
+}
+
+func TestNodeShapes(t *testing.T) {
+	tests := []struct {
+		src            string
+		leaf, isScalar bool
+	}{
+		{`port 80`, true, true},
+		{`(u16)port (u16)80`, true, true},
+		{`empty`, true, false},
+		{`empty {}`, true, false},
+		{`ports 80 443`, true, false},
+		{`limits cpu=2 mem=512`, true, false},
+		{`server 80 host=h`, true, false},
+		{`server 80 { tls; }`, false, false},
+		{`group { a 1; }`, false, false},
+	}
+	for _, tt := range tests {
+		doc, err := kdl.ParseString(tt.src)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", tt.src, err)
+		}
+		n := doc.Nodes[0]
+		if got := n.IsLeaf(); got != tt.leaf {
+			t.Errorf("%q: IsLeaf() = %v, want %v", tt.src, got, tt.leaf)
+		}
+		if got := n.IsScalar(); got != tt.isScalar {
+			t.Errorf("%q: IsScalar() = %v, want %v", tt.src, got, tt.isScalar)
+		}
+	}
+}
+
+func TestNodeOrderedProperties(t *testing.T) {
+	doc, err := kdl.ParseString("server z=1 a=2 m=3 a=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := doc.Nodes[0]
+
+	collect := func() (keys []string, values []int) {
+		for k, v := range n.OrderedProperties() {
+			keys = append(keys, k)
+			values = append(values, v.Int())
+		}
+		return keys, values
+	}
+	keys, values := collect()
+	if want := []string{"z", "a", "m"}; !slices.Equal(keys, want) {
+		t.Errorf("OrderedProperties() keys = %v, want %v", keys, want)
+	}
+	if want := []int{1, 4, 3}; !slices.Equal(values, want) {
+		t.Errorf("OrderedProperties() values = %v, want %v", values, want)
+	}
+
+	// keys added to the map directly come last, sorted
+	n.Properties()["y"] = kdl.NewInt(5)
+	n.Properties()["b"] = kdl.NewInt(6)
+	keys, _ = collect()
+	if want := []string{"z", "a", "m", "b", "y"}; !slices.Equal(keys, want) {
+		t.Errorf("OrderedProperties() keys = %v, want %v", keys, want)
+	}
+
+	for k := range n.OrderedProperties() {
+		if k != "z" {
+			t.Errorf("OrderedProperties() yielded %q after break", k)
+		}
+		break
+	}
+}
+
+type marshalerFunc func() (*kdl.Node, error)
+
+func (f marshalerFunc) MarshalKDL() (*kdl.Node, error) { return f() }
+
+type valueReceiverItem struct{ name string }
+
+func (i valueReceiverItem) MarshalKDL() (*kdl.Node, error) { return kdl.NewNode(i.name), nil }
+
+type pointerReceiverItem struct{ name string }
+
+func (i *pointerReceiverItem) MarshalKDL() (*kdl.Node, error) { return kdl.NewNode(i.name), nil }
+
+func (i *pointerReceiverItem) UnmarshalKDL(n *kdl.Node) error {
+	i.name = n.Name()
+	return nil
+}
+
+func TestMarshalAllReceivers(t *testing.T) {
+	names := func(nodes []*kdl.Node) string {
+		var sb strings.Builder
+		for _, n := range nodes {
+			sb.WriteString(n.Name())
+		}
+		return sb.String()
+	}
+
+	got, err := kdl.MarshalAll([]valueReceiverItem{{"a"}, {"b"}})
+	if err != nil || names(got) != "ab" {
+		t.Errorf("MarshalAll(values, value receiver) = %v, %v; want [a b]", got, err)
+	}
+	got, err = kdl.MarshalAll([]*valueReceiverItem{{"a"}, {"b"}})
+	if err != nil || names(got) != "ab" {
+		t.Errorf("MarshalAll(pointers, value receiver) = %v, %v; want [a b]", got, err)
+	}
+	got, err = kdl.MarshalAll([]pointerReceiverItem{{"a"}, {"b"}})
+	if err != nil || names(got) != "ab" {
+		t.Errorf("MarshalAll(values, pointer receiver) = %v, %v; want [a b]", got, err)
+	}
+	got, err = kdl.MarshalAll([]*pointerReceiverItem{{"a"}, {"b"}})
+	if err != nil || names(got) != "ab" {
+		t.Errorf("MarshalAll(pointers, pointer receiver) = %v, %v; want [a b]", got, err)
+	}
+	if _, err := kdl.MarshalAll([]string{"x"}); err == nil || !strings.Contains(err.Error(), "string does not implement kdl.Marshaler") {
+		t.Errorf("MarshalAll([]string) error = %v, want a Marshaler error", err)
+	}
+
+	// MarshalAll and UnmarshalAll round-trip
+	items, err := kdl.UnmarshalAll[pointerReceiverItem](got)
+	if err != nil {
+		t.Fatalf("UnmarshalAll() error = %v", err)
+	}
+	back, err := kdl.MarshalAll(items)
+	if err != nil || names(back) != "ab" {
+		t.Errorf("MarshalAll(UnmarshalAll()) = %v, %v; want [a b]", back, err)
+	}
+}
+
+func TestNodeMarshalChildrenFunc(t *testing.T) {
+	ok := func(name string) kdl.Marshaler {
+		return marshalerFunc(func() (*kdl.Node, error) { return kdl.NewNode(name), nil })
+	}
+	errBad := errors.New("bad child")
+	bad := marshalerFunc(func() (*kdl.Node, error) { return nil, errBad })
+
+	n := kdl.NewNode("parent")
+	err := n.MarshalChildrenFunc(func() ([]kdl.Marshaler, error) {
+		return []kdl.Marshaler{ok("a"), ok("b")}, nil
+	})
+	if err != nil {
+		t.Fatalf("MarshalChildrenFunc() error = %v", err)
+	}
+	if got := n.Children().Nodes; len(got) != 2 || got[1].Name() != "b" || got[1].Parent() != n {
+		t.Errorf("children = %v, want [a b] with parent set", got)
+	}
+
+	err = n.MarshalChildrenFunc(func() ([]kdl.Marshaler, error) {
+		return []kdl.Marshaler{ok("c"), bad}, nil
+	})
+	if !errors.Is(err, errBad) {
+		t.Errorf("MarshalChildrenFunc() error = %v, want %v", err, errBad)
+	}
+	err = n.MarshalChildrenFunc(func() ([]kdl.Marshaler, error) { return nil, errBad })
+	if !errors.Is(err, errBad) {
+		t.Errorf("MarshalChildrenFunc() error = %v, want %v", err, errBad)
+	}
+	if got := len(n.Children().Nodes); got != 2 {
+		t.Errorf("got %d children after failed calls, want 2", got)
+	}
+}
+
+func TestPrinterCompact(t *testing.T) {
+	doc, err := kdl.ParseString(`(t)server "main" port=80 { child #null; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const pretty = `(document
+  (node "server"
+    (type "t")
+    (argument (string "main"))
+    (property "port" (integer 80))
+    (node "child"
+      (argument (null)))))`
+	if got := kdl.PrintDocument(doc); got != pretty {
+		t.Errorf("PrintDocument() =\n%s\nwant\n%s", got, pretty)
+	}
+
+	const compact = `(document (node "server" (type "t") (argument (string "main")) (property "port" (integer 80)) (node "child" (argument (null)))))`
+	p := kdl.NewCompactPrinter()
+	p.PrintDocument(doc)
+	if got := p.String(); got != compact {
+		t.Errorf("compact PrintDocument() =\n%s\nwant\n%s", got, compact)
+	}
+}
+
+func TestPrinterEscaping(t *testing.T) {
+	doc := kdl.NewDocument(
+		kdl.NewNode("say \"hi\"").
+			WithTypeAnnotation("a\"b").
+			AddArgument(kdl.NewString("line 1\nsaid \"x\"").Annotated("t\n")).
+			AddProperty("k\"ey", kdl.NewString("v")),
+	)
+	const want = `(document
+  (node "say \"hi\""
+    (type "a\"b")
+    (argument (string "line 1\nsaid \"x\""
+      (type "t\n")))
+    (property "k\"ey" (string "v"))))`
+	if got := kdl.PrintDocument(doc); got != want {
+		t.Errorf("PrintDocument() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPrinterValueAnnotations(t *testing.T) {
+	doc, err := kdl.ParseString(`node (truthy)#true (nothing)#null (n)1 key=(truthy)#false`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Nodes[0].AddArgument(kdl.Value{}.Annotated("bad"))
+	const want = `(document
+  (node "node"
+    (argument (boolean true
+      (type "truthy")))
+    (argument (null
+      (type "nothing")))
+    (argument (integer 1
+      (type "n")))
+    (argument (unknown <kdl.Invalid <nil>>
+      (type "bad")))
+    (property "key" (boolean false
+      (type "truthy")))))`
+	if got := kdl.PrintDocument(doc); got != want {
+		t.Errorf("PrintDocument() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNodeString(t *testing.T) {
+	doc, err := kdl.ParseString("outer { (t)server \"main\" port=80 { child; }; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := doc.Nodes[0].GetChild("server")
+	const want = "(t)server main port=80 {\n    child\n}"
+	if got := server.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := fmt.Sprint(server.GetChild("child")); got != "child" {
+		t.Errorf("fmt.Sprint(child) = %q, want child", got)
+	}
+	if server.Parent() != doc.Nodes[0] {
+		t.Error("String() changed the node's parent")
+	}
+
+	bad := kdl.NewNode("n").AddArgument(kdl.NewInt(1)).AddArgument(kdl.Value{})
+	if got, want := bad.String(), `(node "n" (argument (integer 1)) (argument (unknown <kdl.Invalid <nil>>)))`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestNodeValidate(t *testing.T) {
+	doc, err := kdl.ParseString("server 1 a=1 b=2 { child x=1; other; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := doc.Nodes[0]
+	if err := server.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+
+	delete(server.Properties(), "a")
+	server.Properties()["c"] = kdl.NewInt(3)
+	server.Arguments()[0] = kdl.Value{}
+	child := server.GetChild("child")
+	child.Properties()["x"] = kdl.Value{}
+	server.Children().Nodes = append(server.Children().Nodes, nil)
+
+	err = server.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want errors")
+	}
+	want := []string{
+		`kdl: node server: property "a" is in PropertyOrder but not Properties`,
+		`kdl: node server: property "c" is in Properties but not PropertyOrder`,
+		`kdl: node server: argument 0 has an invalid value`,
+		`kdl: node server/child: property "x" has an invalid value`,
+		`kdl: node server: child 2 is nil`,
+	}
+	if got := err.Error(); got != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+}
@@ -21,12 +21,18 @@ type lexer struct {
 	readOffset    Pos
 	ch            rune
 	modeStack     []lexerMode
-	errors        []error
+	errors        []lexError
 	errorHandlers []func(Pos, error)
 	trace         io.Writer
 	version       Version
 }
 
+// A lexError is an error reported by the lexer at a position in the source.
+type lexError struct {
+	pos Pos
+	err error
+}
+
 type lexerMode int
 
 const (
@@ -71,13 +77,19 @@ func (l *lexer) Restore(state LexerState) {
 
 func (l *lexer) errorf(offset Pos, format string, args ...any) {
 	err := fmt.Errorf(format, args...)
-	l.errors = append(l.errors, fmt.Errorf("lex error at %s: %w", l.file.Location(offset), err))
+	l.errors = append(l.errors, lexError{offset, err})
 	for _, handler := range l.errorHandlers {
 		handler(offset, err)
 	}
 }
 
+// AddErrorHandler registers fn to be called with each lex error. fn is first
+// called with any errors reported before it was added, such as for the first
+// character, which newLexer reads.
 func (l *lexer) AddErrorHandler(fn func(Pos, error)) {
+	for _, e := range l.errors {
+		fn(e.pos, e.err)
+	}
 	l.errorHandlers = append(l.errorHandlers, fn)
 }
 
@@ -109,7 +121,7 @@ func (l *lexer) next() {
 	} else {
 		// not ASCII, multi-byte
 		ch, width := utf8.DecodeRune(l.file.src[l.readOffset:])
-		if ch == utf8.RuneError {
+		if ch == utf8.RuneError && width == 1 {
 			l.errorf(l.offset, "invalid UTF-8 encoding")
 		}
 		l.readOffset += Pos(width)
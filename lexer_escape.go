@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 func unescapeString(s string, v Version) (string, error) {
@@ -119,34 +120,69 @@ func unescapeString(s string, v Version) (string, error) {
 }
 
 // EscapeString returns the escaped form of s, suitable for use in a KDL string
-// literal. The result is not wrapped in quotes.
+// literal. The result is not wrapped in quotes. It is equivalent to
+// [EscapeStringMode] with [EscapeDefault].
 func EscapeString(s string, v Version) string {
+	return EscapeStringMode(s, v, EscapeDefault)
+}
+
+// EscapeStringMode is like [EscapeString] but uses the given [EscapeMode] to
+// decide which characters to escape. Quotes, backslashes, and characters that
+// cannot appear literally in a quoted string for version v are always escaped.
+func EscapeStringMode(s string, v Version, mode EscapeMode) string {
+	// Most strings need no escaping, so only build a new string once the first
+	// character that does is found; s[start:i] is the pending literal text.
 	var result strings.Builder
-	for _, ch := range s {
-		switch ch {
-		case 0x000A:
-			result.WriteString(`\n`)
-		case 0x000D:
-			result.WriteString(`\r`)
-		case 0x0009:
-			result.WriteString(`\t`)
-		case 0x005C:
-			result.WriteString(`\\`)
-		case 0x0022:
-			result.WriteString(`\"`)
-		case 0x0008:
-			result.WriteString(`\b`)
-		case 0x000C:
-			result.WriteString(`\f`)
-		case 0x0020:
-			result.WriteString(` `) // no need to escape space
-		default:
-			if isDisallowedChar(ch) {
-				fmt.Fprintf(&result, `\u{%X}`, ch)
-			} else {
-				result.WriteRune(ch)
+	start := 0
+	for i := 0; i < len(s); {
+		ch, width := utf8.DecodeRuneInString(s[i:])
+		var esc string
+		switch {
+		case ch == utf8.RuneError && width == 1:
+			esc = string(utf8.RuneError)
+		case ch == 0x005C:
+			esc = `\\`
+		case ch == 0x0022:
+			esc = `\"`
+		case ch == 0x0009:
+			if mode&EscapeTab != 0 {
+				esc = `\t`
+			}
+		case isNewline(ch):
+			// v2 single-line strings can't contain literal newlines
+			if mode&EscapeNewline == 0 && v == Version1 {
+				break
 			}
+			switch ch {
+			case 0x000A:
+				esc = `\n`
+			case 0x000D:
+				esc = `\r`
+			case 0x000C:
+				esc = `\f`
+			default:
+				esc = fmt.Sprintf(`\u{%X}`, ch)
+			}
+		case ch == 0x0008:
+			esc = `\b`
+		case isDisallowedChar(ch):
+			esc = fmt.Sprintf(`\u{%X}`, ch)
+		case ch > 0x7F && mode&EscapeASCII != 0:
+			esc = fmt.Sprintf(`\u{%X}`, ch)
 		}
+		if esc != "" {
+			if start == 0 {
+				result.Grow(len(s) + len(esc))
+			}
+			result.WriteString(s[start:i])
+			result.WriteString(esc)
+			start = i + width
+		}
+		i += width
+	}
+	if start == 0 {
+		return s
 	}
+	result.WriteString(s[start:])
 	return result.String()
 }
@@ -71,6 +71,12 @@ func searchInts(a []int, x int) int {
 	return i - 1
 }
 
+// A Span is a half-open range [Start, End) of byte offsets in the source.
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
 func (l Location) String() string {
 	if l.Filename != "" {
 		return fmt.Sprintf("%s:%d:%d", l.Filename, l.Line, l.Column)
@@ -0,0 +1,408 @@
+// Map conversion for KDL documents.
+
+package kdl
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DocumentFromMap builds a document from a nested map, as a quick way to
+// generate KDL from data that is already held in maps. Each entry of m becomes
+// a node named after its key, in sorted key order, as follows:
+//
+//   - nil becomes a node with a single #null argument;
+//   - a scalar (anything accepted by [TryNewValue], including [Value]) becomes
+//     a node with that value as its single argument;
+//   - a map with string keys becomes a node whose children are built from the
+//     map by the same rules;
+//   - a slice or array of scalars becomes a node with one argument per element
+//     (an empty slice gives a node with no arguments);
+//   - a slice or array of maps becomes one node per element, each with the
+//     element's entries as children.
+//
+// DocumentFromMap returns an error naming the offending key for a value of any
+// other type, including a slice that mixes maps and scalars or nests slices.
+// [Document.ToMap] performs the inverse conversion.
+func DocumentFromMap(m map[string]any) (*Document, error) {
+	doc := NewDocument()
+	if err := addMapNodes(doc, "", reflect.ValueOf(m)); err != nil {
+		return nil, fmt.Errorf("kdl.DocumentFromMap: %w", err)
+	}
+	return doc, nil
+}
+
+// addMapNodes adds a node to doc for each entry of the map m, whose key
+// kind has already been checked to be string.
+func addMapNodes(doc *Document, prefix string, m reflect.Value) error {
+	keys := make([]string, 0, m.Len())
+	for _, k := range m.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		v := m.MapIndex(reflect.ValueOf(k).Convert(m.Type().Key()))
+		if err := addMapNode(doc, prefix+k, k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addMapNode(doc *Document, path, name string, v reflect.Value) error {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if isScalarMapValue(v) {
+		value, err := mapValue(path, v)
+		if err != nil {
+			return err
+		}
+		doc.AddNode(NewNode(name, value))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%s: unsupported map key type %s", path, v.Type().Key())
+		}
+		node := NewNode(name)
+		if err := addMapNodes(node.Children(), path+"/", v); err != nil {
+			return err
+		}
+		doc.AddNode(node)
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		var maps, scalars int
+		elems := make([]reflect.Value, v.Len())
+		for i := range elems {
+			e := v.Index(i)
+			for e.Kind() == reflect.Interface && !e.IsNil() {
+				e = e.Elem()
+			}
+			switch {
+			case isScalarMapValue(e):
+				scalars++
+			case e.Kind() == reflect.Map:
+				maps++
+			default:
+				return fmt.Errorf("%s: element %d: unsupported value of type %s", path, i, e.Type())
+			}
+			elems[i] = e
+		}
+		if maps > 0 && scalars > 0 {
+			return fmt.Errorf("%s: slice mixes maps and scalars", path)
+		}
+		if maps > 0 {
+			for _, e := range elems {
+				if err := addMapNode(doc, path, name, e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		node := NewNode(name)
+		for i, e := range elems {
+			value, err := mapValue(fmt.Sprintf("%s: element %d", path, i), e)
+			if err != nil {
+				return err
+			}
+			node.AddArgument(value)
+		}
+		doc.AddNode(node)
+		return nil
+
+	default:
+		return fmt.Errorf("%s: unsupported value of type %s", path, v.Type())
+	}
+}
+
+// isScalarMapValue reports whether v becomes a single argument in
+// DocumentFromMap, as opposed to children or multiple arguments.
+func isScalarMapValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true // untyped nil
+	}
+	switch v.Kind() {
+	case reflect.Map, reflect.Array:
+		return false
+	case reflect.Slice:
+		return v.Type().Elem().Kind() == reflect.Uint8 // []byte is a string
+	case reflect.Interface:
+		return true // nil interface
+	}
+	return true
+}
+
+func mapValue(path string, v reflect.Value) (Value, error) {
+	if !v.IsValid() || ((v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) && v.IsNil()) {
+		return NewNull(), nil
+	}
+	value, err := TryNewValue(v.Interface())
+	if err != nil {
+		return Value{}, fmt.Errorf("%s: unsupported value of type %s", path, v.Type())
+	}
+	return value, nil
+}
+
+// ToMap converts the document to a nested map, for templating and other
+// generic processing. Each node becomes an entry keyed by its name, whose value
+// depends on the node's contents:
+//
+//   - a node with a single argument becomes that argument's raw value, as
+//     returned by [Value.RawValue] (nil for #null, and a copy for a
+//     [*big.Int] or [*big.Float]);
+//   - a node with no arguments or with several becomes a []any of their raw
+//     values;
+//   - a node with properties or children becomes a map[string]any holding its
+//     properties and, converted by the same rules, its children. If the node
+//     also has arguments, they are stored under the key "-" as a []any, so
+//     `server 1 2 { port 80 }` becomes {"-": [1, 2], "port": 80}. Children
+//     take precedence over properties (and both over "-") when names collide.
+//
+// When several nodes at the same level share a name, the entry is a []any with
+// the value of each node in order. Type annotations, comments, and the
+// difference between one node with several arguments and several nodes with
+// one argument each are lost, so ToMap followed by [DocumentFromMap] gives back
+// an equivalent document only for simple documents: those with unique node
+// names or nodes that are repeated only with children, and without
+// properties. ToMap never returns nil.
+func (d *Document) ToMap() map[string]any {
+	m := make(map[string]any, len(d.Nodes))
+	counts := make(map[string]int, len(d.Nodes))
+	for _, n := range d.Nodes {
+		counts[n.name]++
+	}
+	for _, n := range d.Nodes {
+		v := nodeMapValue(n)
+		if counts[n.name] == 1 {
+			m[n.name] = v
+			continue
+		}
+		list, _ := m[n.name].([]any)
+		m[n.name] = append(list, v)
+	}
+	return m
+}
+
+func nodeMapValue(n *Node) any {
+	if len(n.props) == 0 && len(n.children.Nodes) == 0 {
+		if len(n.args) == 1 {
+			return mapRawValue(n.args[0])
+		}
+		return rawValues(n.args)
+	}
+	m := make(map[string]any, len(n.props)+len(n.children.Nodes)+1)
+	if len(n.args) > 0 {
+		m["-"] = rawValues(n.args)
+	}
+	for _, k := range n.propOrder {
+		m[k] = mapRawValue(n.props[k])
+	}
+	for k, v := range n.children.ToMap() {
+		m[k] = v
+	}
+	return m
+}
+
+func rawValues(values []Value) []any {
+	raw := make([]any, len(values))
+	for i, v := range values {
+		raw[i] = mapRawValue(v)
+	}
+	return raw
+}
+
+// mapRawValue returns the raw value of v for ToMap, copying big numbers so
+// that changes to the map cannot reach the document.
+func mapRawValue(v Value) any {
+	switch v.kind {
+	case BigInt:
+		return v.BigInt()
+	case BigFloat:
+		return v.BigFloat()
+	}
+	return v.raw
+}
+
+// Flatten converts the document to a flat map from paths to the values of
+// every argument and property, for exporting to key-value stores or comparing
+// documents key by key. Paths use the same scheme as [Change]: node names
+// joined with slashes, with "[n]" appended to the second and later nodes of the
+// same name under the same parent, followed by "#" and the index of an
+// argument or "." and the key of a property, and with names and keys that are
+// empty or contain any of / . # [ ] " quoted. For example,
+//
+//	server {
+//	    database "main" host=db.local
+//	    route "/"
+//	    route "/api"
+//	}
+//
+// flattens to
+//
+//	server/database#0    "main"
+//	server/database.host "db.local"
+//	server/route#0       "/"
+//	server/route[1]#0    "/api"
+//
+// Nodes without arguments or properties have no entries of their own, and
+// comments are dropped. Flatten never returns nil.
+func Flatten(doc *Document) map[string]Value {
+	m := map[string]Value{}
+	flattenNodes(m, "", doc.Nodes)
+	return m
+}
+
+func flattenNodes(m map[string]Value, prefix string, nodes []*Node) {
+	seen := map[string]int{}
+	for _, n := range nodes {
+		path := nthNodePath(prefix, n.name, seen[n.name])
+		seen[n.name]++
+		for i, v := range n.args {
+			m[path+"#"+strconv.Itoa(i)] = v
+		}
+		for _, k := range n.propOrder {
+			m[path+"."+pathSegment(k)] = n.props[k]
+		}
+		flattenNodes(m, path+"/", n.children.Nodes)
+	}
+}
+
+// Unflatten builds a document from a map in the format produced by [Flatten].
+// Nodes and properties are created in the sorted order of the keys of m, and
+// missing arguments or repeated nodes are filled in with #null arguments and
+// empty nodes. Node names and property keys
+// may be quoted KDL strings, as Flatten writes them when needed. Empty nodes
+// without children are lost by Flatten, so they are not restored.
+//
+// Unflatten returns an error naming the offending key if a key does not end in
+// an argument index or property key, or has a malformed node index or quoted
+// name.
+func Unflatten(m map[string]Value) (*Document, error) {
+	keys := slices.Sorted(maps.Keys(m))
+	doc := NewDocument()
+	for _, key := range keys {
+		if err := unflattenKey(doc, key, m[key]); err != nil {
+			return nil, fmt.Errorf("kdl.Unflatten: key %q: %w", key, err)
+		}
+	}
+	return doc, nil
+}
+
+func unflattenKey(doc *Document, key string, v Value) error {
+	rest := key
+	for {
+		name, nth, r, err := cutNodePathSegment(rest)
+		if err != nil {
+			return err
+		}
+		for doc.countNamed(name) <= nth {
+			doc.AddNode(NewNode(name))
+		}
+		n := doc.Nodes[nthNodeNamed(doc.Nodes, name, nth)]
+		doc = n.Children()
+
+		if r == "" {
+			return errors.New("missing argument index or property key")
+		}
+		switch r[0] {
+		case '/':
+			rest = r[1:]
+		case '.':
+			if r[1:] == "" {
+				return errors.New("empty property key")
+			}
+			k, tail, err := cutPathName(r[1:])
+			if err != nil {
+				return err
+			}
+			if tail != "" {
+				return fmt.Errorf("unexpected %q after property key", tail)
+			}
+			n.AddProperty(k, v)
+			return nil
+		case '#':
+			i, err := strconv.Atoi(r[1:])
+			if err != nil || i < 0 {
+				return fmt.Errorf("invalid argument index %q", r[1:])
+			}
+			n.SetArg(i, v)
+			return nil
+		default:
+			return fmt.Errorf("unexpected %q after node name", r)
+		}
+	}
+}
+
+// cutNodePathSegment parses the node path segment at the start of s, such as
+// "route[1]", into a node name and zero-based index, and returns the rest of s.
+func cutNodePathSegment(s string) (name string, nth int, rest string, err error) {
+	name, rest, err = cutPathName(s)
+	if err != nil {
+		return "", 0, "", err
+	}
+	if name == "" && !strings.HasPrefix(s, `"`) {
+		return "", 0, "", errors.New("empty node name")
+	}
+	if !strings.HasPrefix(rest, "[") {
+		return name, 0, rest, nil
+	}
+	head := s[:len(s)-len(rest)]
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		if i := strings.IndexAny(rest, "/#."); i >= 0 {
+			rest = rest[:i]
+		}
+		return "", 0, "", fmt.Errorf("malformed node index in %q", head+rest)
+	}
+	nth, err = strconv.Atoi(rest[1:end])
+	if err != nil || nth < 0 {
+		return "", 0, "", fmt.Errorf("malformed node index in %q", head+rest[:end+1])
+	}
+	return name, nth, rest[end+1:], nil
+}
+
+// cutPathName parses the node name or property key at the start of s, either a
+// quoted KDL string or bare text up to the next separator, and returns the rest
+// of s.
+func cutPathName(s string) (name, rest string, err error) {
+	if !strings.HasPrefix(s, `"`) {
+		i := strings.IndexAny(s, "/[#.")
+		if i < 0 {
+			i = len(s)
+		}
+		return s[:i], s[i:], nil
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			name, err := unescapeString(s[1:i], Version2)
+			if err != nil {
+				return "", "", fmt.Errorf("malformed quoted name %s: %w", s[:i+1], err)
+			}
+			return name, s[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted name in %q", s)
+}
+
+// countNamed returns the number of nodes in d with the given name.
+func (d *Document) countNamed(name string) int {
+	count := 0
+	for _, n := range d.Nodes {
+		if n.name == name {
+			count++
+		}
+	}
+	return count
+}
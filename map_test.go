@@ -0,0 +1,252 @@
+package kdl_test
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/calico32/kdl-go"
+)
+
+func TestDocumentFromMap(t *testing.T) {
+	doc, err := kdl.DocumentFromMap(map[string]any{
+		"name":    "app",
+		"port":    8080,
+		"debug":   false,
+		"missing": nil,
+		"tags":    []string{"a", "b"},
+		"empty":   []any{},
+		"server": map[string]any{
+			"host":  "localhost",
+			"ratio": 0.5,
+		},
+		"route": []map[string]any{
+			{"path": "/"},
+			{"path": "/api"},
+		},
+		"value": kdl.NewInt(1).Annotated("u8"),
+	})
+	if err != nil {
+		t.Fatalf("DocumentFromMap() error = %v", err)
+	}
+	got, err := kdl.EmitToString(doc)
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	want := `debug #false
+empty
+missing #null
+name app
+port 8080
+route {
+    path "/"
+}
+route {
+    path "/api"
+}
+server {
+    host localhost
+    ratio 0.5
+}
+tags a b
+value (u8)1
+`
+	if got != want {
+		t.Errorf("DocumentFromMap() =\n%s\nwant:\n%s", got, want)
+	}
+
+	errTests := []struct {
+		m    map[string]any
+		want string
+	}{
+		{map[string]any{"ch": make(chan int)}, "ch: unsupported value of type chan int"},
+		{map[string]any{"a": map[string]any{"f": func() {}}}, "a/f: unsupported value of type func()"},
+		{map[string]any{"mixed": []any{1, map[string]any{}}}, "mixed: slice mixes maps and scalars"},
+		{map[string]any{"nested": []any{[]int{1}}}, "nested: element 0: unsupported value of type []int"},
+		{map[string]any{"keys": map[int]any{1: 2}}, "keys: unsupported map key type int"},
+	}
+	for _, tt := range errTests {
+		_, err := kdl.DocumentFromMap(tt.m)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("DocumentFromMap(%v) error = %v, want %q", tt.m, err, tt.want)
+		}
+	}
+}
+
+func TestDocumentToMap(t *testing.T) {
+	doc, err := kdl.ParseString(`
+name app
+port (u16)8080
+tags a b
+empty
+missing #null
+route { path "/"; }
+route { path "/api"; }
+x 1
+x 2
+server 1 2 host=h port=1 { port 80; }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := doc.ToMap()
+	want := map[string]any{
+		"name":    "app",
+		"port":    8080,
+		"tags":    []any{"a", "b"},
+		"empty":   []any{},
+		"missing": nil,
+		"route": []any{
+			map[string]any{"path": "/"},
+			map[string]any{"path": "/api"},
+		},
+		"x":      []any{1, 2},
+		"server": map[string]any{"-": []any{1, 2}, "host": "h", "port": 80},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToMap() = %#v\nwant %#v", got, want)
+	}
+
+	// big numbers are copied, so changing the map leaves the document alone
+	bigDoc, err := kdl.ParseString("n 123456789012345678901234567890\nf 1e400\nl 1 123456789012345678901234567890 p=123456789012345678901234567890")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := bigDoc.String()
+	bm := bigDoc.ToMap()
+	bm["n"].(*big.Int).SetInt64(1)
+	bm["f"].(*big.Float).SetInt64(1)
+	bm["l"].(map[string]any)["-"].([]any)[1].(*big.Int).SetInt64(1)
+	bm["l"].(map[string]any)["p"].(*big.Int).SetInt64(1)
+	if after := bigDoc.String(); after != before {
+		t.Errorf("document changed through ToMap() result:\n%s\nwant:\n%s", after, before)
+	}
+
+	if m := kdl.NewDocument().ToMap(); m == nil || len(m) != 0 {
+		t.Errorf("ToMap() of empty document = %#v, want empty map", m)
+	}
+
+	// simple documents round-trip through DocumentFromMap
+	simple, err := kdl.ParseString(`
+debug #false
+route {
+    path "/"
+}
+route {
+    path "/api"
+}
+server {
+    host localhost
+    ratio 0.5
+}
+tags a b
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := kdl.DocumentFromMap(simple.ToMap())
+	if err != nil {
+		t.Fatalf("DocumentFromMap() error = %v", err)
+	}
+	if !back.Equal(simple) {
+		a, _ := kdl.EmitToString(back)
+		t.Errorf("round trip = \n%s", a)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	doc, err := kdl.ParseString(`
+server {
+    database "main" host=db.local
+    route "/"
+    route "/api" 2
+}
+ports 80 443
+empty
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := kdl.Flatten(doc)
+	want := map[string]kdl.Value{
+		"server/database#0":    kdl.NewString("main"),
+		"server/database.host": kdl.NewString("db.local"),
+		"server/route#0":       kdl.NewString("/"),
+		"server/route[1]#0":    kdl.NewString("/api"),
+		"server/route[1]#1":    kdl.NewInt(2),
+		"ports#0":              kdl.NewInt(80),
+		"ports#1":              kdl.NewInt(443),
+	}
+	if len(got) != len(want) {
+		t.Errorf("Flatten() has %d entries, want %d: %v", len(got), len(want), got)
+	}
+	for k, v := range want {
+		if !kdl.ValuesEqual(got[k], v) {
+			t.Errorf("Flatten()[%q] = %v, want %v", k, got[k], v)
+		}
+	}
+
+	back, err := kdl.Unflatten(got)
+	if err != nil {
+		t.Fatalf("Unflatten() error = %v", err)
+	}
+	s, _ := kdl.EmitToString(back)
+	const wantDoc = `ports 80 443
+server {
+    database main host=db.local
+    route "/"
+    route "/api" 2
+}
+`
+	if s != wantDoc {
+		t.Errorf("Unflatten() =\n%s\nwant:\n%s", s, wantDoc)
+	}
+
+	// gaps are filled with #null arguments and empty nodes
+	gaps, err := kdl.Unflatten(map[string]kdl.Value{"a[2]#1": kdl.NewInt(1)})
+	if err != nil {
+		t.Fatalf("Unflatten() error = %v", err)
+	}
+	if s, _ := kdl.EmitToString(gaps); s != "a\na\na #null 1\n" {
+		t.Errorf("Unflatten() = %q", s)
+	}
+
+	// names and keys that contain separators are quoted and restored
+	odd, err := kdl.ParseString(`"a/b" "x.y"=1 { "" "#"=2 "q\"" 3; }` + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flat := kdl.Flatten(odd)
+	for _, k := range []string{`"a/b"."x.y"`, `"a/b"/""."#"`, `"a/b"/""#0`} {
+		if _, ok := flat[k]; !ok {
+			t.Errorf("Flatten() = %v, want a %s key", flat, k)
+		}
+	}
+	if back, err := kdl.Unflatten(flat); err != nil || !back.Equal(odd) {
+		s, _ := kdl.EmitToString(back)
+		t.Errorf("Unflatten(Flatten()) = %q, %v; want %s", s, err, odd)
+	}
+
+	errTests := []struct {
+		key  string
+		want string
+	}{
+		{"server", "missing argument index or property key"},
+		{"server/port#x", `invalid argument index "x"`},
+		{"server/port#-1", `invalid argument index "-1"`},
+		{"server.", "empty property key"},
+		{"route[x]#0", `malformed node index in "route[x]"`},
+		{"route[1#0", `malformed node index in "route[1"`},
+		{"a//b#0", "empty node name"},
+		{`"a#0`, "unterminated quoted name"},
+		{`"a"b#0`, `unexpected "b#0" after node name`},
+		{`a."k"x`, `unexpected "x" after property key`},
+	}
+	for _, tt := range errTests {
+		_, err := kdl.Unflatten(map[string]kdl.Value{tt.key: kdl.NewInt(1)})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Unflatten(%q) error = %v, want %q", tt.key, err, tt.want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package kdl
 
+import "fmt"
+
 // A ValueMarshaler can marshal itself to a KDL Value.
 type ValueMarshaler interface {
 	MarshalKDLValue() (Value, error)
@@ -30,6 +32,13 @@ type DocumentUnmarshaler interface {
 	UnmarshalKDLDocument(doc *Document) error
 }
 
+// A Validator can check itself after being decoded. When a struct type (or a
+// pointer to it) implements Validator, the decoder calls Validate once all of
+// the struct's fields have been filled, and returns any error it reports.
+type Validator interface {
+	Validate() error
+}
+
 // MarshalNodes marshals the given [Marshaler]s and adds them to the document's nodes.
 func (d *Document) MarshalNodes(nodes ...Marshaler) error {
 	if cap(d.Nodes)-len(d.Nodes) < len(nodes) {
@@ -41,8 +50,25 @@ func (d *Document) MarshalNodes(nodes ...Marshaler) error {
 		if err != nil {
 			return err
 		}
-		d.Nodes = append(d.Nodes, n)
+		d.AddNode(n)
+	}
+	return nil
+}
+
+// MarshalChildrenFunc calls fn and marshals each of the returned [Marshaler]s,
+// adding the resulting nodes as children of n, like [Node.AddChildrenFunc]. If
+// fn or any MarshalKDL call returns an error, no children are added and the
+// error is returned.
+func (n *Node) MarshalChildrenFunc(fn func() ([]Marshaler, error)) error {
+	items, err := fn()
+	if err != nil {
+		return err
+	}
+	children, err := MarshalAll(items)
+	if err != nil {
+		return err
 	}
+	n.AddChildren(children...)
 	return nil
 }
 
@@ -54,6 +80,8 @@ type unmarshalable[T any] interface {
 
 // UnmarshalAll unmarshals the given nodes into a provided [Unmarshaler] type. It returns the first
 // error encountered during unmarshaling, or nil if all nodes were successfully unmarshaled.
+// UnmarshalKDL must be declared on *T, as it has to modify the new value; an
+// implementation with a value receiver would only fill in a copy.
 func UnmarshalAll[T any, U unmarshalable[T]](nodes []*Node) ([]*T, error) {
 	out := make([]*T, 0, len(nodes))
 	for _, node := range nodes {
@@ -66,12 +94,49 @@ func UnmarshalAll[T any, U unmarshalable[T]](nodes []*Node) ([]*T, error) {
 	return out, nil
 }
 
-// MarshalAll marshals the given [Marshaler]s and returns a slice of the
-// resulting KDL nodes.
-func MarshalAll[T Marshaler](items []T) ([]*Node, error) {
+// GetChildAs finds the first child of node with the given name and unmarshals
+// it into a new T using its [Unmarshaler] implementation, for reading nested
+// blocks alongside the [FirstArg]-style helpers used for scalars:
+//
+//	// database { host db.local; port 5432; }
+//	db, err := kdl.GetChildAs[Database](node, "database")
+//
+// If node has no such child, GetChildAs returns an error wrapping
+// [ErrNotFound] without calling UnmarshalKDL. GetChildAs panics if node is nil.
+func GetChildAs[T any, U unmarshalable[T]](node *Node, name string) (*T, error) {
+	if node == nil {
+		panic("kdl.GetChildAs: nil node")
+	}
+
+	child := node.GetChild(name)
+	if child == nil {
+		return nil, fmt.Errorf("%w: node %s has no child %s", ErrNotFound, node.name, name)
+	}
+	item := new(T)
+	if err := U(item).UnmarshalKDL(child); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// MarshalAll marshals each of items with its [Marshaler] implementation and
+// returns a slice of the resulting KDL nodes, mirroring [UnmarshalAll].
+// MarshalKDL may be declared on either T or *T: if T does not implement
+// Marshaler but *T does, it is called on a pointer to the slice element, so
+// a []Config can be marshaled whichever receiver Config.MarshalKDL has. It
+// returns the first error encountered, or an error if neither T nor *T
+// implements Marshaler.
+func MarshalAll[T any](items []T) ([]*Node, error) {
 	out := make([]*Node, 0, len(items))
-	for _, item := range items {
-		node, err := item.MarshalKDL()
+	for i := range items {
+		m, ok := any(items[i]).(Marshaler)
+		if !ok {
+			m, ok = any(&items[i]).(Marshaler)
+		}
+		if !ok {
+			return nil, fmt.Errorf("kdl.MarshalAll: %T does not implement kdl.Marshaler", items[i])
+		}
+		node, err := m.MarshalKDL()
 		if err != nil {
 			return nil, err
 		}
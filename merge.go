@@ -0,0 +1,100 @@
+package kdl
+
+import "slices"
+
+// A MergeStrategy controls how [Document.Merge] combines nodes from two
+// documents.
+type MergeStrategy uint8
+
+const (
+	// MergeReplace replaces each matching node in the base document with the
+	// node from the other document, at the base node's position.
+	MergeReplace MergeStrategy = iota
+	// MergeAppend appends every node from the other document after the nodes
+	// of the base document, without matching nodes at all.
+	MergeAppend
+	// MergeDeep merges each matching pair of nodes: the other node's type
+	// annotation (if any) and arguments (if it has any) replace the base
+	// node's, its properties are set on the base node, overriding properties
+	// with the same key, and the children of the two nodes are merged
+	// recursively with MergeDeep.
+	MergeDeep
+)
+
+// Merge returns a new document combining d with other according to strategy,
+// for example to layer a document of overrides on top of a document of
+// defaults. Neither d nor other is modified; all nodes in the result are
+// clones.
+//
+// Nodes are matched by name. The first node named "x" in other matches the
+// first node named "x" in d, the second matches the second, and so on; nodes in
+// other without a match are appended to the result in order. This means a node
+// that appears once in each document is always merged with its counterpart,
+// regardless of its position or arguments.
+func (d *Document) Merge(other *Document, strategy MergeStrategy) *Document {
+	merged := d.Clone()
+	mergeNodes(merged, other.Nodes, strategy)
+	return merged
+}
+
+// mergeNodes merges clones of nodes into dst, which must already hold clones.
+func mergeNodes(dst *Document, nodes []*Node, strategy MergeStrategy) {
+	if strategy == MergeAppend {
+		for _, n := range nodes {
+			dst.AddNode(n.Clone())
+		}
+		return
+	}
+
+	seen := map[string]int{}
+	for _, n := range nodes {
+		i := nthNodeNamed(dst.Nodes, n.name, seen[n.name])
+		seen[n.name]++
+		switch {
+		case i < 0:
+			dst.AddNode(n.Clone())
+		case strategy == MergeReplace:
+			dst.Nodes[i] = n.Clone()
+			dst.adopt(dst.Nodes[i])
+		default:
+			mergeNode(dst.Nodes[i], n)
+		}
+	}
+}
+
+// nthNodeNamed returns the index of the nth (zero-based) node with the given
+// name, or -1 if there are not that many.
+func nthNodeNamed(nodes []*Node, name string, nth int) int {
+	for i, n := range nodes {
+		if n.name == name {
+			if nth == 0 {
+				return i
+			}
+			nth--
+		}
+	}
+	return -1
+}
+
+// mergeNode deep-merges src into dst, which must be a clone.
+func mergeNode(dst, src *Node) {
+	if src.typeValid {
+		dst.typ, dst.typeValid = src.typ, true
+	}
+	if len(src.args) > 0 {
+		dst.args = slices.Clone(src.args)
+		dst.argComments = slices.Clone(src.argComments)
+		// arguments now precede all properties
+		dst.entries = dst.entries[:0]
+		for range dst.args {
+			dst.entries = append(dst.entries, nodeEntryArg)
+		}
+		for range dst.propEntries {
+			dst.entries = append(dst.entries, nodeEntryProp)
+		}
+	}
+	for _, key := range src.propOrder {
+		dst.SetProp(key, src.props[key])
+	}
+	mergeNodes(&dst.children, src.children.Nodes, MergeDeep)
+}
@@ -0,0 +1,81 @@
+package kdl
+
+import "testing"
+
+func TestDocumentMerge(t *testing.T) {
+	const base = `server main port=80 host=localhost {
+    tls enabled=#false
+    log level=info
+}
+route "/"
+route "/api"
+`
+	const overrides = `server port=8080 {
+    tls enabled=#true cert=x.pem
+    timeout 30
+}
+route "/v2"
+route "/v2/api" auth=#true
+route "/extra"
+(env)extra
+`
+
+	tests := []struct {
+		name     string
+		strategy MergeStrategy
+		want     string
+	}{
+		{"replace", MergeReplace, `server port=8080 {
+    tls enabled=#true cert=x.pem
+    timeout 30
+}
+route "/v2"
+route "/v2/api" auth=#true
+route "/extra"
+(env)extra
+`},
+		{"append", MergeAppend, base + overrides},
+		{"deep", MergeDeep, `server main port=8080 host=localhost {
+    tls enabled=#true cert=x.pem
+    log level=info
+    timeout 30
+}
+route "/v2"
+route "/v2/api" auth=#true
+route "/extra"
+(env)extra
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := ParseString(base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ParseString(overrides)
+			if err != nil {
+				t.Fatal(err)
+			}
+			aBefore, bBefore := a.Clone(), b.Clone()
+
+			merged := a.Merge(b, tt.strategy)
+			got, err := EmitToString(merged)
+			if err != nil {
+				t.Fatalf("Emit() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Merge() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if !a.EqualOrdered(aBefore) || !b.EqualOrdered(bBefore) {
+				t.Error("Merge() modified its inputs")
+			}
+			if err := merged.Nodes[0].Validate(); err != nil {
+				t.Errorf("merged node is inconsistent: %v", err)
+			}
+			if child := merged.Nodes[0].Children().Nodes[0]; child.Parent() != merged.Nodes[0] {
+				t.Error("merged child has the wrong parent")
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package kdl
 
 import (
+	"errors"
 	"fmt"
+	"iter"
 	"maps"
 	"slices"
+	"strings"
 )
 
 // A nodeEntryKind tags an entry in a Node's args/props insertion order.
@@ -42,6 +45,7 @@ type Node struct {
 	// args[i] and the i-th nodeEntryProp refers to propEntries[i].
 	entries  []nodeEntryKind
 	children Document
+	parent   *Node
 
 	// hints controls the behavior of the emitter when serializing the node.
 	hints EmitterHints
@@ -57,9 +61,19 @@ type Node struct {
 	// leadingComments holds comments that appear on lines before this node.
 	leadingComments []Comment
 
+	// comment is set by SetComment and emitted as // lines before this node.
+	comment string
+
 	// trailingComment holds a single-line comment that appears on the same line
 	// as this node (after all arguments, properties, and children).
 	trailingComment *Comment
+	// trailingCommentSet is true when trailingComment was set by
+	// SetTrailingComment, in which case Emit writes it too.
+	trailingCommentSet bool
+
+	// argComments holds comments set by SetArgumentComment, indexed like args.
+	// It may be shorter than args.
+	argComments []string
 
 	// inlineSlashdashes holds /- comments on individual args, props, or children
 	// blocks within this node's body, in source order.
@@ -102,6 +116,9 @@ func (n *Node) Arguments() []Value { return n.args }
 // Properties returns the properties of the KDL node.
 func (n *Node) Properties() map[string]Value { return n.props }
 
+// ArgumentCount returns the number of arguments of the KDL node.
+func (n *Node) ArgumentCount() int { return len(n.args) }
+
 // Arg returns the argument at the given index. It returns the zero Value if the
 // index is out of range.
 func (n *Node) Arg(index int) Value {
@@ -120,6 +137,48 @@ func (n *Node) Prop(key string) Value {
 	return Value{}
 }
 
+// GetArgument returns the argument at the given index and whether the index is
+// in range.
+func (n *Node) GetArgument(index int) (Value, bool) {
+	if index < 0 || index >= len(n.args) {
+		return Value{}, false
+	}
+	return n.args[index], true
+}
+
+// GetProperty returns the property with the given key and whether it exists.
+// Only the node's own properties are consulted, not its children.
+func (n *Node) GetProperty(key string) (Value, bool) {
+	val, ok := n.props[key]
+	return val, ok
+}
+
+// Single returns the node's only argument, for nodes of the common
+// `key value` shape. If the node has no arguments, the returned error wraps
+// [ErrNotFound]; if it has more than one, a non-nil error is returned as well.
+// Properties and children are ignored.
+func (n *Node) Single() (Value, error) {
+	switch len(n.args) {
+	case 0:
+		return Value{}, fmt.Errorf("%w: node %s has no arguments", ErrNotFound, n.name)
+	case 1:
+		return n.args[0], nil
+	default:
+		return Value{}, fmt.Errorf("node %s does not have exactly one argument", n.name)
+	}
+}
+
+// HasArgumentAt reports whether the node has an argument at the given index.
+func (n *Node) HasArgumentAt(index int) bool {
+	return index >= 0 && index < len(n.args)
+}
+
+// HasProperty reports whether the node has a property with the given key.
+func (n *Node) HasProperty(key string) bool {
+	_, ok := n.props[key]
+	return ok
+}
+
 func (n *Node) SetArg(index int, value Value) {
 	if index < 0 {
 		panic(fmt.Sprintf("kdl.Set: negative argument index %d", index))
@@ -171,6 +230,41 @@ func (n *Node) PropertyEntries() []KV {
 	return out
 }
 
+// OrderedProperties returns an iterator over the node's properties in
+// [Node.PropertyOrder], yielding each key once with its (last-wins) value. Keys
+// that are in [Node.Properties] but missing from the order, which can only
+// happen if the map was modified directly, are yielded last in sorted order.
+func (n *Node) OrderedProperties() iter.Seq2[string, Value] {
+	return func(yield func(string, Value) bool) {
+		seen := 0
+		for _, key := range n.propOrder {
+			v, ok := n.props[key]
+			if !ok {
+				continue
+			}
+			seen++
+			if !yield(key, v) {
+				return
+			}
+		}
+		if seen == len(n.props) {
+			return
+		}
+		var rest []string
+		for key := range n.props {
+			if !slices.Contains(n.propOrder, key) {
+				rest = append(rest, key)
+			}
+		}
+		slices.Sort(rest)
+		for _, key := range rest {
+			if !yield(key, n.props[key]) {
+				return
+			}
+		}
+	}
+}
+
 // PropertyEntryKeyLocation returns the source range of the key token for the
 // i-th property occurrence. Returns ok=false when the index is out of range
 // or location tracking is off for that entry.
@@ -219,6 +313,52 @@ func (n *Node) removeNthEntry(k nodeEntryKind, idx int) bool {
 	return false
 }
 
+// Parent returns the node whose children include n, or nil if n is a top-level
+// node or has not been added to a node. It is set by the parser and by the
+// methods that add nodes to a [Document] or Node, such as [Node.AddChild]; it
+// is not updated when Document.Nodes is modified directly.
+func (n *Node) Parent() *Node { return n.parent }
+
+// String returns the node and its children as KDL version 2 text, as emitted by
+// [Emit] with default options, without a trailing newline. If the node cannot
+// be emitted (for example, because it contains an invalid [Value]), String
+// falls back to the single-line S-expression form produced by a compact
+// [Printer], which can represent any node.
+func (n *Node) String() string {
+	s, err := EmitToString(&Document{Nodes: []*Node{n}})
+	if err != nil {
+		p := NewCompactPrinter()
+		p.PrintNode(n)
+		return strings.TrimPrefix(p.String(), " ")
+	}
+	return strings.TrimSuffix(s, "\n")
+}
+
+// Ancestors returns the chain of ancestors of n, starting with its parent and
+// ending with the top-level node (see [Node.Parent]). It returns an empty slice
+// if n has no parent.
+func (n *Node) Ancestors() []*Node {
+	ancestors := []*Node{}
+	for p := n.parent; p != nil; p = p.parent {
+		ancestors = append(ancestors, p)
+	}
+	return ancestors
+}
+
+// Path returns the names of n's ancestors and n itself joined with slashes,
+// such as "server/database/host". For a node without a parent it is just the
+// node's name. The result is in the form accepted by [Document.At], though At
+// returns the first node matching each name, which may not be n.
+func (n *Node) Path() string {
+	ancestors := n.Ancestors()
+	names := make([]string, 0, len(ancestors)+1)
+	for _, a := range slices.Backward(ancestors) {
+		names = append(names, a.name)
+	}
+	names = append(names, n.name)
+	return strings.Join(names, "/")
+}
+
 // Children returns the children of the KDL node.
 func (n *Node) Children() *Document { return &n.children }
 
@@ -235,6 +375,11 @@ func (n *Node) NameEndLocation() Location { return n.nameEndLoc }
 // node was programmatically created.
 func (n *Node) EndLocation() Location { return n.endLoc }
 
+// Span returns the byte range of the node in the source file, from the start of
+// the node name through its last argument, property, or closing brace. Returns a
+// zero Span when location tracking is off.
+func (n *Node) Span() Span { return Span{n.loc.Offset, n.endLoc.Offset} }
+
 // TypeAnnotationRange returns the source range of the type annotation content
 // (the identifier inside the parentheses, not the parens themselves). ok is
 // false when no type annotation is present or location tracking is off.
@@ -273,6 +418,14 @@ func (n *Node) setPropertyKeyLocation(key string, start, end Location) {
 // Hints returns the emitter hints for the KDL node.
 func (n *Node) Hints() *EmitterHints { return &n.hints }
 
+// SetEmitEmptyChildren sets the [EmitterHints.EmitEmptyChildren] hint of the
+// KDL node and returns the node. When set, the emitter writes an empty children
+// block for the node even if it has no children.
+func (n *Node) SetEmitEmptyChildren(v bool) *Node {
+	n.hints.EmitEmptyChildren = v
+	return n
+}
+
 // HasBlankLineBefore reports whether a blank line preceded this node in the
 // parsed source.
 func (n *Node) HasBlankLineBefore() bool { return n.blankLineBefore }
@@ -280,8 +433,25 @@ func (n *Node) HasBlankLineBefore() bool { return n.blankLineBefore }
 // LeadingComments returns comments that appear on lines before this node in the
 // parsed source. Single-line and multi-line comments are preserved exactly;
 // slashdash comments carry the commented-out node for re-formatting.
+//
+// Comments are always recorded by the parser, but only [Format] re-emits them;
+// [Emit] ignores them. Multi-line comments that appear within a node's body
+// (e.g. between two arguments) are treated as whitespace and are not preserved.
 func (n *Node) LeadingComments() []Comment { return n.leadingComments }
 
+// SetComment sets a comment to write before the KDL node and returns the node.
+// Unlike [Node.LeadingComments], which only [Format] re-emits, the comment is
+// written by both [Emit] and [Format], as one // line per line of text. Pass ""
+// to remove the comment.
+func (n *Node) SetComment(text string) *Node {
+	n.comment = text
+	return n
+}
+
+// Comment returns the comment set with [Node.SetComment], or "" if there is
+// none.
+func (n *Node) Comment() string { return n.comment }
+
 // TrailingComment returns the single-line comment on the same line as this node,
 // if any. ok is false when no trailing comment is present.
 func (n *Node) TrailingComment() (c Comment, ok bool) {
@@ -291,6 +461,48 @@ func (n *Node) TrailingComment() (c Comment, ok bool) {
 	return *n.trailingComment, true
 }
 
+// SetTrailingComment sets a single-line comment to write at the end of the KDL
+// node's line, after its arguments, properties, and children, and returns the
+// node. Any newlines in text are replaced with spaces. The comment replaces the
+// node's [Node.TrailingComment] and, unlike one from parsed source, is written
+// by [Emit] as well as [Format]. Pass "" to remove the trailing comment.
+func (n *Node) SetTrailingComment(text string) *Node {
+	if text == "" {
+		n.trailingComment, n.trailingCommentSet = nil, false
+		return n
+	}
+	n.trailingComment = &Comment{kind: CommentSingleLine, text: "// " + singleLineComment(text) + "\n"}
+	n.trailingCommentSet = true
+	return n
+}
+
+// SetArgumentComment sets a comment to write after the argument at the given
+// index and returns the node. Any newlines in text are replaced with spaces. If
+// the argument ends the node's line, the comment is written as a trailing //
+// comment, and if only the children block follows, it is written after the
+// block's opening brace; otherwise it is written after an escline (\) so that
+// the rest of the node continues on the next line. Pass "" to remove the
+// comment. If the index is out of bounds, SetArgumentComment does nothing.
+func (n *Node) SetArgumentComment(index int, text string) *Node {
+	if index < 0 || index >= len(n.args) {
+		return n
+	}
+	for len(n.argComments) <= index {
+		n.argComments = append(n.argComments, "")
+	}
+	n.argComments[index] = singleLineComment(text)
+	return n
+}
+
+// ArgumentComment returns the comment set with [Node.SetArgumentComment] for
+// the argument at the given index, or "" if there is none.
+func (n *Node) ArgumentComment(index int) string {
+	if index < 0 || index >= len(n.argComments) {
+		return ""
+	}
+	return n.argComments[index]
+}
+
 // InlineSlashdashes returns the ordered list of /- comments on args, props, and
 // children blocks within this node's body.
 func (n *Node) InlineSlashdashes() []InlineSlashdash { return n.inlineSlashdashes }
@@ -310,6 +522,7 @@ func NewNode(name string, args ...Value) *Node {
 		name:  name,
 		props: map[string]Value{},
 	}
+	n.children.owner = n
 	for _, arg := range args {
 		n.AddArgument(arg)
 	}
@@ -330,6 +543,14 @@ func NewKValue(name string, value Value) *Node {
 	return NewNode(name, value)
 }
 
+// WithTypeAnnotation sets the type annotation of the KDL node to ty and returns
+// the node.
+func (n *Node) WithTypeAnnotation(ty string) *Node {
+	n.typ = ty
+	n.typeValid = true
+	return n
+}
+
 // AddArgument adds a value as an argument to the KDL node and returns the node.
 func (n *Node) AddArgument(value Value) *Node {
 	n.args = append(n.args, value)
@@ -337,6 +558,17 @@ func (n *Node) AddArgument(value Value) *Node {
 	return n
 }
 
+// WithArgumentAnnotated adds v as an argument with the type annotation ty to
+// the KDL node and returns the node. v may be a [Value] or any type accepted by
+// [NewValue]; WithArgumentAnnotated panics if v cannot be wrapped.
+func (n *Node) WithArgumentAnnotated(ty string, v any) *Node {
+	val, ok := v.(Value)
+	if !ok {
+		val = NewValue(v)
+	}
+	return n.AddArgument(val.Annotated(ty))
+}
+
 // RemoveArgument removes the argument at the given index from the KDL node and
 // returns the node. If the index is out of bounds, RemoveArgument does nothing.
 func (n *Node) RemoveArgument(index int) *Node {
@@ -344,6 +576,9 @@ func (n *Node) RemoveArgument(index int) *Node {
 		return n
 	}
 	n.args = append(n.args[:index], n.args[index+1:]...)
+	if index < len(n.argComments) {
+		n.argComments = slices.Delete(n.argComments, index, index+1)
+	}
 	n.removeNthEntry(nodeEntryArg, index)
 	return n
 }
@@ -408,6 +643,38 @@ func (n *Node) AddChild(child *Node) *Node {
 	return n
 }
 
+// InsertChild inserts a child node into the KDL node at the given index, as
+// described in [Document.InsertNode].
+func (n *Node) InsertChild(index int, child *Node) error {
+	return n.children.InsertNode(index, child)
+}
+
+// RemoveChild removes the first child with the given name from the KDL node and
+// reports whether a child was removed.
+func (n *Node) RemoveChild(name string) bool {
+	i := slices.IndexFunc(n.children.Nodes, func(c *Node) bool { return c.name == name })
+	if i < 0 {
+		return false
+	}
+	n.children.Nodes[i].parent = nil
+	n.children.Nodes = slices.Delete(n.children.Nodes, i, i+1)
+	return true
+}
+
+// RemoveChildren removes every child with the given name from the KDL node and
+// returns the number of children removed.
+func (n *Node) RemoveChildren(name string) int {
+	before := len(n.children.Nodes)
+	n.children.Nodes = slices.DeleteFunc(n.children.Nodes, func(c *Node) bool {
+		if c.name == name {
+			c.parent = nil
+			return true
+		}
+		return false
+	})
+	return before - len(n.children.Nodes)
+}
+
 // AddKV adds a key-value pair as a child node with the given name and value and
 // returns the parent node.
 func (n *Node) AddKV(name string, value Value) *Node {
@@ -460,6 +727,18 @@ func (n *Node) GetChild(name string) *Node {
 	return nil
 }
 
+// HasChild reports whether any of the node's children has the given name.
+// Every child is checked, not only the first, but grandchildren and deeper
+// descendants are not; use [Node.At] to look up a nested path.
+func (n *Node) HasChild(name string) bool {
+	return n.GetChild(name) != nil
+}
+
+// At is like [Document.At], but looks up path relative to the children of n.
+func (n *Node) At(path string) (*Node, error) {
+	return lookupPath(&n.children, path)
+}
+
 type KV struct {
 	Key   string
 	Value Value
@@ -491,7 +770,117 @@ func (n *Node) GetChildren(name string) []*Node {
 	return children
 }
 
-// Clone creates a deep copy of the KDL node and returns it.
+// IsLeaf reports whether the node has no children. Its arguments and
+// properties are not considered, and an empty children block counts as no
+// children.
+func (n *Node) IsLeaf() bool { return len(n.children.Nodes) == 0 }
+
+// IsScalar reports whether the node has the `key value` shape: exactly one
+// argument, no properties, and no children. The type annotations of the node
+// and its argument are not considered. For a scalar node, [Node.Single]
+// returns the argument without error.
+func (n *Node) IsScalar() bool {
+	return len(n.args) == 1 && len(n.props) == 0 && n.IsLeaf()
+}
+
+// NumChildren returns the total number of children of the KDL node.
+func (n *Node) NumChildren() int { return len(n.children.Nodes) }
+
+// CountChildren returns the number of children with the given name, without
+// allocating a slice as [Node.GetChildren] does. The empty string is a valid
+// node name, so CountChildren("") counts the children named ""; use
+// [Node.NumChildren] for the total.
+func (n *Node) CountChildren(name string) int {
+	return n.children.countNamed(name)
+}
+
+// Equal reports whether n and other are structurally equal: they have the same
+// name, type annotation, arguments (in order), properties (in any order), and
+// children (recursively, in order). Values are compared with [ValuesEqual].
+// Comments, hints, source locations, and literal spellings are not compared.
+//
+// Use [Node.EqualOrdered] to also require properties to be in the same order.
+func (n *Node) Equal(other *Node) bool {
+	return n.equal(other, false)
+}
+
+// EqualOrdered is like [Node.Equal], but also requires the properties of n and
+// other (and of all their children) to be in the same [Node.PropertyOrder].
+func (n *Node) EqualOrdered(other *Node) bool {
+	return n.equal(other, true)
+}
+
+func (n *Node) equal(other *Node, ordered bool) bool {
+	if n == nil || other == nil {
+		return n == other
+	}
+	if n.name != other.name || n.typeValid != other.typeValid || (n.typeValid && n.typ != other.typ) {
+		return false
+	}
+	if !slices.EqualFunc(n.args, other.args, ValuesEqual) {
+		return false
+	}
+	if !maps.EqualFunc(n.props, other.props, ValuesEqual) {
+		return false
+	}
+	if ordered && !slices.Equal(n.propOrder, other.propOrder) {
+		return false
+	}
+	return n.children.equal(&other.children, ordered)
+}
+
+// Validate checks that the KDL node and its descendants are internally
+// consistent, which may not be the case after modifying the slices and maps
+// returned by [Node.Arguments], [Node.Properties], [Node.PropertyOrder], or
+// [Node.Children] directly. It reports:
+//
+//   - keys in PropertyOrder that are missing from Properties, and vice versa
+//   - arguments and properties holding an invalid (zero) [Value]
+//   - nil children
+//
+// Validate returns nil if no problems are found, or an error joining one error
+// per problem (see [errors.Join]) otherwise.
+func (n *Node) Validate() error {
+	var errs []error
+	n.validate(n.Path(), &errs)
+	return errors.Join(errs...)
+}
+
+func (n *Node) validate(path string, errs *[]error) {
+	report := func(format string, args ...any) {
+		*errs = append(*errs, fmt.Errorf("kdl: node %s: %s", path, fmt.Sprintf(format, args...)))
+	}
+	for _, key := range n.propOrder {
+		if _, ok := n.props[key]; !ok {
+			report("property %q is in PropertyOrder but not Properties", key)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(n.props)) {
+		if !slices.Contains(n.propOrder, key) {
+			report("property %q is in Properties but not PropertyOrder", key)
+		}
+		if !n.props[key].IsValid() {
+			report("property %q has an invalid value", key)
+		}
+	}
+	for i, arg := range n.args {
+		if !arg.IsValid() {
+			report("argument %d has an invalid value", i)
+		}
+	}
+	for i, child := range n.children.Nodes {
+		if child == nil {
+			report("child %d is nil", i)
+			continue
+		}
+		child.validate(path+"/"+child.name, errs)
+	}
+}
+
+// Clone creates a deep copy of the KDL node and returns it. Arguments and
+// properties are copied by value; values are immutable (accessors such as
+// [Value.BigInt] return copies), so the clone never aliases the original. The
+// clone has no [Node.Parent]; the parent of each cloned child is the clone.
 func (n *Node) Clone() *Node {
 	clone := &Node{
 		name:            n.name,
@@ -504,6 +893,8 @@ func (n *Node) Clone() *Node {
 		props:           make(map[string]Value, len(n.props)),
 		children:        Document{Nodes: make([]*Node, 0, len(n.children.Nodes))},
 		hints:           n.hints,
+		comment:         n.comment,
+		argComments:     slices.Clone(n.argComments),
 		blankLineBefore: n.blankLineBefore,
 		loc:             n.loc,
 		nameEndLoc:      n.nameEndLoc,
@@ -523,6 +914,7 @@ func (n *Node) Clone() *Node {
 	if n.trailingComment != nil {
 		c := *n.trailingComment
 		clone.trailingComment = &c
+		clone.trailingCommentSet = n.trailingCommentSet
 	}
 	if len(n.inlineSlashdashes) > 0 {
 		clone.inlineSlashdashes = make([]InlineSlashdash, len(n.inlineSlashdashes))
@@ -551,8 +943,9 @@ func (n *Node) Clone() *Node {
 		clone.propKeyStart = maps.Clone(n.propKeyStart)
 		clone.propKeyEnd = maps.Clone(n.propKeyEnd)
 	}
+	clone.children.owner = clone
 	for _, child := range n.children.Nodes {
-		clone.children.Nodes = append(clone.children.Nodes, child.Clone())
+		clone.children.AddNode(child.Clone())
 	}
 	if len(n.children.TrailingComments) > 0 {
 		clone.children.TrailingComments = make([]Comment, len(n.children.TrailingComments))
@@ -1,6 +1,10 @@
 package kdl
 
-import "io"
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
 
 // ======================== interfaces ========================
 
@@ -47,6 +51,7 @@ func (f marshalOptionFunc) encodeOption()             {}
 
 type EmitOption interface {
 	EncodeOption
+	WriteFileOption
 	applyEmitter(*emitter)
 }
 
@@ -54,6 +59,12 @@ type emitterOptionFunc func(*emitter)
 
 func (f emitterOptionFunc) applyEmitter(e *emitter) { f(e) }
 func (f emitterOptionFunc) encodeOption()           {}
+func (f emitterOptionFunc) writeFileOption()        {}
+
+// A WriteFileOption is an option for [WriteFile]. As writing a file is emitting
+// a document into it, WriteFileOptions are the EmitOptions plus
+// [WithAtomicWrite].
+type WriteFileOption interface{ writeFileOption() }
 
 /// ======================== common options ========================
 
@@ -65,6 +76,7 @@ func (v versionOption) applyParser(p *parser)   { p.version = Version(v) }
 func (v versionOption) applyEmitter(e *emitter) { e.version = Version(v) }
 func (v versionOption) decodeOption()           {}
 func (v versionOption) encodeOption()           {}
+func (v versionOption) writeFileOption()        {}
 
 type traceOption struct{ io.Writer }
 
@@ -99,6 +111,14 @@ func WithLocations(v bool) ParseOption {
 	return parseOptionFunc(func(p *parser) { p.withLocations = v })
 }
 
+// WithMaxDepth sets the maximum nesting depth of children blocks. Deeper
+// documents fail to parse with an error instead of recursing further, which
+// protects against stack exhaustion on untrusted input. A value of 0 or less
+// disables the limit. Default: [DefaultMaxDepth].
+func WithMaxDepth(n int) ParseOption {
+	return parseOptionFunc(func(p *parser) { p.maxDepth = n })
+}
+
 // DupMode controls how the parser reacts to repeated property keys on a node.
 type DupMode uint8
 
@@ -119,6 +139,8 @@ const (
 // WithDuplicateProperties controls how the parser reacts when a node has the
 // same property key more than once. The KDL spec says the rightmost value
 // wins; this option lets callers surface duplicates as warnings or errors.
+// With [DupError], [Parse] and [ParseString] fail on the first duplicate, with
+// an error naming the node and the repeated key.
 func WithDuplicateProperties(mode DupMode) ParseOption {
 	return parseOptionFunc(func(p *parser) {
 		p.duplicateProps = mode
@@ -135,6 +157,16 @@ func WithStrict(strict bool) UnmarshalOption {
 	return unmarshalOptionFunc(func(d *decoder) { d.strict = strict })
 }
 
+// WithDisallowUnknownNodes specifies whether the decoder returns an error
+// wrapping [ErrUnknownNode] when a node or child node does not map to any
+// struct field and there is no children field to receive it, as with
+// [encoding/json.Decoder.DisallowUnknownFields]. This is useful for catching
+// typos in configuration files. Unlike [WithStrict], it does not affect
+// arguments, properties, or value conversions. It is disabled by default.
+func WithDisallowUnknownNodes(v bool) UnmarshalOption {
+	return unmarshalOptionFunc(func(d *decoder) { d.disallowUnknown = v })
+}
+
 // ======================== marshal options ========================
 
 // (none yet)
@@ -146,6 +178,28 @@ func WithIndent(s string) EmitOption {
 	return emitterOptionFunc(func(e *emitter) { e.indent = s })
 }
 
+// WithIndentWidth sets the indent to n repetitions of the indent character
+// (a space unless changed with [WithIndentChar]). Negative widths are clamped
+// to zero, which disables indentation.
+func WithIndentWidth(n int) EmitOption {
+	return emitterOptionFunc(func(e *emitter) {
+		e.indent = strings.Repeat(string(e.indentChar), max(n, 0))
+	})
+}
+
+// WithIndentChar sets the character repeated for each level of indentation
+// (e.g. ' ' or '\t'), keeping the width of the current indent: four by
+// default, or the number of characters set with [WithIndentWidth] or
+// [WithIndent]. For example, WithIndent("  ") followed by WithIndentChar('\t')
+// indents by two tabs; use WithIndentChar('\t') together with WithIndentWidth(1)
+// for one tab per level.
+func WithIndentChar(c rune) EmitOption {
+	return emitterOptionFunc(func(e *emitter) {
+		e.indentChar = c
+		e.indent = strings.Repeat(string(c), utf8.RuneCountInString(e.indent))
+	})
+}
+
 // WithStringAlwaysQuote sets whether to always quote strings.
 func WithStringAlwaysQuote(v bool) EmitOption {
 	return emitterOptionFunc(func(e *emitter) { e.stringAlwaysQuote = v })
@@ -153,33 +207,39 @@ func WithStringAlwaysQuote(v bool) EmitOption {
 
 // WithFloatCapitalExponent sets whether to use capital 'E' for exponents.
 func WithFloatCapitalExponent(v bool) EmitOption {
-	return emitterOptionFunc(func(e *emitter) { e.floatCapitalExponent = v })
+	return emitterOptionFunc(func(e *emitter) { e.floatFormat.CapitalExponent = v })
 }
 
 // WithFloatMinExponent sets the minimum exponent for using scientific notation.
 func WithFloatMinExponent(v int) EmitOption {
-	return emitterOptionFunc(func(e *emitter) { e.floatMinExponent = v })
+	return emitterOptionFunc(func(e *emitter) { e.floatFormat.MinExponent = v })
 }
 
 // WithFloatPlus sets whether to include '+' for positive floats.
 func WithFloatPlus(v bool) EmitOption {
-	return emitterOptionFunc(func(e *emitter) { e.floatPlus = v })
+	return emitterOptionFunc(func(e *emitter) { e.floatFormat.Plus = v })
 }
 
 // WithFloatDecimalPoint sets whether to always include a decimal point in floats.
 func WithFloatDecimalPoint(v bool) EmitOption {
-	return emitterOptionFunc(func(e *emitter) { e.floatDecimalPoint = v })
+	return emitterOptionFunc(func(e *emitter) { e.floatFormat.DecimalPoint = v })
 }
 
 // WithFloatExponentPlus sets whether to include '+' for positive exponents.
 func WithFloatExponentPlus(v bool) EmitOption {
-	return emitterOptionFunc(func(e *emitter) { e.floatExponentPlus = v })
+	return emitterOptionFunc(func(e *emitter) { e.floatFormat.ExponentPlus = v })
 }
 
 // WithFloatDecimalOrExponent sets whether to always include either a decimal
 // point or exponent part in floats.
 func WithFloatDecimalOrExponent(v bool) EmitOption {
-	return emitterOptionFunc(func(e *emitter) { e.floatDecimalOrExponent = v })
+	return emitterOptionFunc(func(e *emitter) { e.floatFormat.DecimalOrExponent = v })
+}
+
+// WithFloatFormat sets all float emission options at once. It overrides any
+// previous WithFloat* options; later ones override it in turn.
+func WithFloatFormat(f FloatFormat) EmitOption {
+	return emitterOptionFunc(func(e *emitter) { e.floatFormat = f })
 }
 
 // WithTestSuiteFloatOptions applies float emission options that match the
@@ -190,10 +250,10 @@ func WithFloatDecimalOrExponent(v bool) EmitOption {
 //   - Always include a '+' for positive exponents
 func WithTestSuiteFloatOptions() EmitOption {
 	return emitterOptionFunc(func(e *emitter) {
-		e.floatCapitalExponent = true
-		e.floatMinExponent = 2
-		e.floatDecimalPoint = true
-		e.floatExponentPlus = true
+		e.floatFormat.CapitalExponent = true
+		e.floatFormat.MinExponent = 2
+		e.floatFormat.DecimalPoint = true
+		e.floatFormat.ExponentPlus = true
 	})
 }
 
@@ -203,11 +263,80 @@ func WithIntegerFormat(f IntegerFormat) EmitOption {
 }
 
 // WithEmitEmptyChildren sets whether to emit an empty children block when
-// a node has no children.
+// a node has no children, so that every node has an explicit children block.
+// When false, the per-node [EmitterHints.EmitEmptyChildren] hint (see
+// [Node.SetEmitEmptyChildren]) can still force an empty block for individual
+// nodes; the hint cannot suppress the block when this option is true.
 func WithEmitEmptyChildren(v bool) EmitOption {
 	return emitterOptionFunc(func(e *emitter) { e.emitEmptyChildren = v })
 }
 
+// WithSortProperties sets whether to emit properties in alphabetical order
+// instead of insertion order.
+func WithSortProperties(v bool) EmitOption {
+	return emitterOptionFunc(func(e *emitter) { e.sortProperties = v })
+}
+
+// WithEscapeMode sets which characters are escaped in quoted strings. See
+// [EscapeMode] for details.
+func WithEscapeMode(m EscapeMode) EmitOption {
+	return emitterOptionFunc(func(e *emitter) { e.escapeMode = m })
+}
+
+// WithNumberLiterals sets whether to write numbers parsed from a document the
+// way they appeared in the source, such as 0xFF, 1_000, or 1.50e3, instead of
+// formatting their values with [WithIntegerFormat] and [WithFloatFormat]. The
+// parser records the source text of every number (see [Value.Literal]); values
+// created with [NewInt], [NewValue], and similar functions, or produced by
+// unmarshaling or conversion, have no source text and are formatted as usual.
+// A value given a format with [Value.WithIntegerFormat] also loses its source
+// text. [Format] always writes number literals. Default: false.
+func WithNumberLiterals(v bool) EmitOption {
+	return emitterOptionFunc(func(e *emitter) { e.numberLiterals = v })
+}
+
+// WithCanonical applies a preset of emission options producing a canonical
+// form, so that documents which differ only in representation emit identical
+// bytes. This is useful for hashing documents and for stable diffs. The
+// canonical form is KDL v2 with:
+//   - every node name, property key, type annotation, and string quoted, with
+//     tabs and newlines escaped ([EscapeDefault]);
+//   - properties sorted by key, after all arguments;
+//   - integers in decimal, ignoring formats set with [Value.WithIntegerFormat],
+//     and floats in [DefaultFloatFormat];
+//   - one node per line, indented by four spaces per level, with a children
+//     block only for nodes that have children, ignoring [EmitterHints];
+//   - no comments, including those set with [Node.SetComment],
+//     [Node.SetTrailingComment], and [Node.SetArgumentComment].
+//
+// Options given after WithCanonical override the corresponding part of the
+// preset, at the cost of canonicity.
+func WithCanonical() EmitOption {
+	return emitterOptionFunc(func(e *emitter) {
+		e.version = Version2
+		e.indentChar, e.indent = ' ', "    "
+		e.stringAlwaysQuote = true
+		e.escapeMode = EscapeDefault
+		e.sortProperties = true
+		e.integerFormat = Decimal
+		e.floatFormat = DefaultFloatFormat()
+		e.emitEmptyChildren = false
+		e.numberLiterals = false
+		e.ignoreHints = true
+		e.omitComments = true
+	})
+}
+
+// ======================== file options ========================
+
+type atomicWriteOption bool
+
+// WithAtomicWrite sets whether [WriteFile] writes the document to a temporary
+// file and renames it into place, instead of writing to the destination file
+// directly. Default: false.
+func WithAtomicWrite(v bool) WriteFileOption { return atomicWriteOption(v) }
+func (atomicWriteOption) writeFileOption()   {}
+
 // ======================== utils ========================
 
 func splitDecodeOptions(opts []DecodeOption) ([]ParseOption, []UnmarshalOption) {
@@ -241,3 +370,15 @@ func splitEncodeOptions(opts []EncodeOption) ([]MarshalOption, []EmitOption) {
 	}
 	return marshalOpts, emitOpts
 }
+
+func splitWriteFileOptions(opts []WriteFileOption) (atomic bool, emitOpts []EmitOption) {
+	for _, opt := range opts {
+		switch opt := opt.(type) {
+		case atomicWriteOption:
+			atomic = bool(opt)
+		case EmitOption:
+			emitOpts = append(emitOpts, opt)
+		}
+	}
+	return atomic, emitOpts
+}
@@ -1,6 +1,8 @@
 package kdl
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -8,24 +10,58 @@ import (
 	"strings"
 )
 
+// ErrVersionMismatch is returned (wrapped in a [*VersionMismatchError]) when a
+// document fails to parse at the version requested with [WithVersion] but
+// parses cleanly at the other version. It can be used with [errors.Is].
+var ErrVersionMismatch = errors.New("kdl version mismatch")
+
+// A VersionMismatchError reports that a document did not parse at the
+// Requested version but would parse at the Suggested version. Err is the first
+// parse error encountered at the requested version.
+type VersionMismatchError struct {
+	Requested Version
+	Suggested Version
+	Err       error
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("%s (document parses as KDL %s, not %s)", e.Err, e.Suggested, e.Requested)
+}
+
+func (e *VersionMismatchError) Unwrap() []error {
+	return []error{ErrVersionMismatch, e.Err}
+}
+
 // Parse parses a KDL document from the provided reader and returns it. If the
 // input is not valid KDL, Parse returns a non-nil error describing the first
-// parse error encountered.
+// parse error encountered. If a version was requested with [WithVersion] and
+// the input only parses at the other version, the error is a
+// [*VersionMismatchError].
 //
 // For more detailed error reporting and diagnostics, use [ParseWithDiagnostics]
 // instead.
 func Parse(r io.Reader, opts ...ParseOption) (*Document, error) {
+	return ParseContext(context.Background(), r, opts...)
+}
+
+// ParseContext is like [Parse], but stops parsing and returns ctx.Err() if ctx
+// is cancelled before the document has been fully parsed. The context is
+// checked between nodes; reading from r is not interrupted.
+func ParseContext(ctx context.Context, r io.Reader, opts ...ParseOption) (*Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	src, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	opts = append(slices.Clone(opts), parseOptionFunc(func(p *parser) { p.ctx = ctx }))
 	result := parseWithDiagnosticsFromBytes(src, opts...)
-	if result.HasErrors() {
-		for _, d := range result.Diagnostics {
-			if d.Severity == SeverityError {
-				return nil, fmt.Errorf("parse error at %s: %s", d.Start, d.Message)
-			}
-		}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := result.err(); err != nil {
+		return nil, err
 	}
 	return result.Document, nil
 }
@@ -65,12 +101,8 @@ func ParseNamedWithDiagnostics(name string, r io.Reader, opts ...ParseOption) (*
 // input is not valid KDL.
 func ParseString(src string, opts ...ParseOption) (*Document, error) {
 	result := parseWithDiagnosticsFromBytes([]byte(src), opts...)
-	if result.HasErrors() {
-		for _, d := range result.Diagnostics {
-			if d.Severity == SeverityError {
-				return nil, fmt.Errorf("parse error at %s: %s", d.Start, d.Message)
-			}
-		}
+	if err := result.err(); err != nil {
+		return nil, err
 	}
 	return result.Document, nil
 }
@@ -105,7 +137,9 @@ func ParseNamedStringWithDiagnostics(name, src string, opts ...ParseOption) *Par
 //
 //   - If an explicit version is requested via WithVersion, the parser uses that
 //     version and that version only, never falling back to a different version.
-//     The document and diagnostics are returned as-is.
+//     The document and diagnostics are returned as-is, except that if there are
+//     errors and the source parses cleanly at the other version, a hint is
+//     added and ParseResult.SuggestedVersion is set.
 //   - Otherwise, the parse is first attempted at v2 and then at v1 if v2 had
 //     errors or if a v1 marker is present. If only one succeeds, that version
 //     is returned. If both succeed or both fail, the marker is consulted as a
@@ -186,7 +220,24 @@ func parseWithDiagnosticsFromBytes(src []byte, opts ...ParseOption) *ParseResult
 			// ignore malformed marker - version option request is already an
 			// explicit override
 		}
-		return &ParseResult{Document: doc, Diagnostics: diags, Version: requested}
+		result := &ParseResult{Document: doc, Diagnostics: diags, Version: requested}
+		if hasErrorDiag(diags) {
+			other := Version1
+			if requested == Version1 {
+				other = Version2
+			}
+			if _, otherDiags := parseAs(other); !hasErrorDiag(otherDiags) {
+				result.SuggestedVersion = other
+				result.Diagnostics = append(result.Diagnostics, Diagnostic{
+					Start:    zeroLoc,
+					End:      zeroLoc,
+					Severity: SeverityHint,
+					Message:  fmt.Sprintf("input parses as KDL %s; parse with WithVersion(%s) instead", other, other),
+					Code:     DiagParseVersionMismatch,
+				})
+			}
+		}
+		return result
 	}
 
 	v2doc, v2diags := parseAs(Version2)
@@ -255,6 +306,22 @@ func parseWithDiagnosticsFromBytes(src []byte, opts ...ParseOption) *ParseResult
 	}
 }
 
+// err returns the error reported by Parse and ParseString for r, or nil if r
+// has no errors.
+func (r *ParseResult) err() error {
+	for _, d := range r.Diagnostics {
+		if d.Severity != SeverityError {
+			continue
+		}
+		err := fmt.Errorf("parse error at %s: %s", d.Start, d.Message)
+		if r.SuggestedVersion != VersionAuto {
+			return &VersionMismatchError{Requested: r.Version, Suggested: r.SuggestedVersion, Err: err}
+		}
+		return err
+	}
+	return nil
+}
+
 // hasErrorDiag reports whether any diagnostic has SeverityError.
 func hasErrorDiag(ds []Diagnostic) bool {
 	for _, d := range ds {
@@ -270,6 +337,7 @@ func newParser(lex *lexer, trace io.Writer, options ...ParseOption) *parser {
 		lexer:         lex,
 		trace:         trace,
 		withLocations: true,
+		maxDepth:      DefaultMaxDepth,
 		version:       VersionAuto,
 	}
 	lex.AddErrorHandler(func(pos Pos, err error) {
@@ -301,8 +369,16 @@ type parser struct {
 	withLocations  bool
 	version        Version
 	duplicateProps DupMode
+	ctx            context.Context // nil unless set by ParseContext
+	maxDepth       int
+	depth          int
+	depthExceeded  bool
 }
 
+// DefaultMaxDepth is the default maximum nesting depth of children blocks
+// accepted by the parser. See [WithMaxDepth].
+const DefaultMaxDepth = 1000
+
 func (p *parser) errorf(pos Pos, code, format string, args ...any) {
 	p.errorfRange(pos, p.token.EndPos, code, format, args...)
 }
@@ -420,10 +496,25 @@ func (p *parser) syncToNodeBoundary() {
 }
 
 func (p *parser) parseNodes() (nodes []*Node, trailing []Comment) {
+	trailing, _ = p.parseNodesFunc(func(node *Node) bool {
+		nodes = append(nodes, node)
+		return true
+	})
+	return
+}
+
+// parseNodesFunc is like parseNodes, but calls yield for each node as soon as
+// it (and its terminator) has been parsed instead of collecting them. If yield
+// returns false, parsing stops and stopped is true.
+func (p *parser) parseNodesFunc(yield func(*Node) bool) (trailing []Comment, stopped bool) {
 	// collect comments and blank lines before the first node
 	pendingComments, pendingBlankLine := p.collectBetweenNodes(0)
 
 	for p.token.Type != tokenEOF && p.token.Type != tokenRBrace {
+		if p.ctx != nil && p.ctx.Err() != nil {
+			return pendingComments, true
+		}
+
 		// top-level slashdash-commented node
 		if p.token.Type == tokenSlashdash {
 			slashStart := p.token.Pos
@@ -488,7 +579,6 @@ func (p *parser) parseNodes() (nodes []*Node, trailing []Comment) {
 			node.blankLineBefore = pendingBlankLine
 			pendingComments = nil
 			pendingBlankLine = false
-			nodes = append(nodes, node)
 		}
 
 		switch p.token.Type {
@@ -530,6 +620,10 @@ func (p *parser) parseNodes() (nodes []*Node, trailing []Comment) {
 			}
 			pendingComments, pendingBlankLine = p.collectBetweenNodes(start)
 		}
+
+		if node != nil && !yield(node) {
+			return pendingComments, true
+		}
 	}
 
 	trailing = pendingComments
@@ -565,6 +659,7 @@ func (p *parser) parseNode() (n *Node) {
 	n = &Node{
 		props: make(map[string]Value),
 	}
+	n.children.owner = n
 	var lastEndPos Pos
 	if p.withLocations {
 		defer func() {
@@ -653,17 +748,30 @@ func (p *parser) parseNode() (n *Node) {
 			// inline: { child1; child2 } — first token after { is not a newline
 			// multiline: {\n  child\n} — first token after { is a newline
 			wasInline := p.token.Type != tokenNewline
+			p.depth++
+			if p.maxDepth > 0 && p.depth > p.maxDepth && !p.depthExceeded {
+				// bail out of the whole document rather than recursing further
+				p.errorf(p.token.Pos, DiagParseMaxDepthExceeded, "max nesting depth of %d exceeded", p.maxDepth)
+				p.depthExceeded = true
+				for p.token.Type != tokenEOF {
+					p.next()
+				}
+			}
 			nodes, childTrailing := p.parseNodes()
+			p.depth--
 
 			if p.token.Type == tokenRBrace {
 				lastEndPos = p.token.EndPos
 				p.next()
 			} else {
 				// missing closing brace — record error, store what we have, return
-				p.errorfRange(p.token.Pos, p.token.EndPos, DiagSyntaxExpectedRBrace, "expected token type }, got %v", p.token.Type)
+				if !p.depthExceeded {
+					p.errorfRange(p.token.Pos, p.token.EndPos, DiagSyntaxExpectedRBrace, "expected token type }, got %v", p.token.Type)
+				}
 				if !slashdash {
 					childrenEncountered = true
 					n.children.Nodes = nodes
+					n.children.adopt(nodes...)
 					n.children.TrailingComments = childTrailing
 					n.childrenInline = &wasInline
 				} else {
@@ -685,6 +793,7 @@ func (p *parser) parseNode() (n *Node) {
 			if !slashdash {
 				childrenEncountered = true
 				n.children.Nodes = nodes
+				n.children.adopt(nodes...)
 				n.children.TrailingComments = childTrailing
 				n.childrenInline = &wasInline
 			} else {
@@ -751,11 +860,11 @@ func (p *parser) parseNode() (n *Node) {
 								Start:    p.lexer.File().Location(keyStart),
 								End:      p.lexer.File().Location(keyEnd),
 								Severity: SeverityWarning,
-								Message:  fmt.Sprintf("duplicate property %q shadows earlier occurrence", s),
+								Message:  fmt.Sprintf("duplicate property %q on node %q shadows earlier occurrence", s, n.name),
 								Code:     DiagSyntaxDuplicateProperty,
 							})
 						case DupError:
-							p.errorfRange(keyStart, keyEnd, DiagSyntaxDuplicateProperty, "duplicate property %q", s)
+							p.errorfRange(keyStart, keyEnd, DiagSyntaxDuplicateProperty, "duplicate property %q on node %q", s, n.name)
 						}
 					}
 				} else {
@@ -1025,46 +1134,64 @@ func (p *parser) parseNumber() Value {
 		fp = strings.ContainsAny(digits, ".eE")
 	case tokenHexadecimal:
 		base = 16
-		digits = digits[2:]
+		digits = stripRadixPrefix(digits)
 	case tokenOctal:
 		base = 8
-		digits = digits[2:]
+		digits = stripRadixPrefix(digits)
 	case tokenBinary:
 		base = 2
-		digits = digits[2:]
+		digits = stripRadixPrefix(digits)
 	default:
 		p.errorExpected(DiagSyntaxExpectedNumber, "number")
 		return NewNull()
 	}
 	p.next()
 
+	v, ok := newNumber(strings.ReplaceAll(digits, "_", ""), base, fp)
+	if !ok {
+		if fp {
+			p.errorf(p.token.Pos, DiagSyntaxInvalidFloat, "invalid float literal: %q", digits)
+		} else {
+			p.errorf(p.token.Pos, DiagSyntaxInvalidInteger, "invalid integer literal: %q", digits)
+		}
+		return NewNull()
+	}
+	return v.WithLiteral(literal)
+}
+
+// stripRadixPrefix removes the 0x/0o/0b prefix from a (possibly signed)
+// integer literal, keeping the sign.
+func stripRadixPrefix(literal string) string {
+	if literal[0] == '+' || literal[0] == '-' {
+		return literal[:1] + literal[3:]
+	}
+	return literal[2:]
+}
+
+// newNumber converts the digits of a number literal (without prefix or
+// underscores) to a Value, using a [BigInt] or [BigFloat] only when the number
+// cannot be represented exactly as an int or float64.
+func newNumber(digits string, base int, fp bool) (Value, bool) {
 	if fp {
 		// floating point
 		var f big.Float
-		_, _, err := f.Parse(strings.ReplaceAll(digits, "_", ""), 10)
-		if err != nil {
-			p.errorf(p.token.Pos, DiagSyntaxInvalidFloat, "invalid float literal: %q", digits)
-			return NewNull()
+		if _, _, err := f.Parse(digits, 10); err != nil {
+			return Value{}, false
 		}
 		f64, prec := f.Float64()
 		if prec == big.Exact {
-			return NewFloat(f64).WithLiteral(literal)
-		} else {
-			return NewBigFloat(&f).WithLiteral(literal)
+			return NewFloat(f64), true
 		}
+		return NewBigFloat(&f), true
 	}
 
 	// integer
-	digits = strings.ReplaceAll(digits, "_", "")
 	var i big.Int
-	_, ok := i.SetString(digits, base)
-	if !ok {
-		p.errorf(p.token.Pos, DiagSyntaxInvalidInteger, "invalid integer literal: %q", digits)
-		return NewNull()
+	if _, ok := i.SetString(digits, base); !ok {
+		return Value{}, false
 	}
 	if i.IsInt64() {
-		return NewInt(int(i.Int64())).WithLiteral(literal)
-	} else {
-		return NewBigInt(&i).WithLiteral(literal)
+		return NewInt(int(i.Int64())), true
 	}
+	return NewBigInt(&i), true
 }
@@ -0,0 +1,206 @@
+package kdl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+)
+
+// ParseNodes returns an iterator over the top-level nodes of the KDL document
+// read from r. Each node is yielded as soon as it has been parsed, and is not
+// retained by the parser afterwards, so callers processing large documents can
+// handle nodes one at a time without building a whole [Document].
+//
+// If the input is not valid KDL, the iterator yields the nodes preceding the
+// first error and then a nil node with a non-nil error, and stops. Errors from
+// r are reported the same way. Stopping the iteration early is always safe.
+//
+// Unlike [Parse], ParseNodes cannot detect the KDL version of its input, since
+// that requires parsing the whole document up front. It parses as [Version2]
+// unless another version is given with [WithVersion]. The input is still read
+// into memory in full before parsing begins.
+func ParseNodes(r io.Reader, opts ...ParseOption) iter.Seq2[*Node, error] {
+	return func(yield func(*Node, error) bool) {
+		src, err := io.ReadAll(r)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		name := "<input>"
+		version := Version2
+		for _, opt := range opts {
+			if v, ok := opt.(versionOption); ok && Version(v) != VersionAuto {
+				version = Version(v)
+			}
+			if n, ok := opt.(sourceNameOption); ok {
+				name = string(n)
+			}
+		}
+
+		vOpts := append(slices.Clone(opts), WithVersion(version))
+		p := newParser(newLexer(name, src, nil, version), nil, vOpts...)
+
+		// only scan diagnostics added since the last check
+		checked := 0
+		firstError := func() error {
+			for _, d := range p.diagnostics[checked:] {
+				if d.Severity == SeverityError {
+					return fmt.Errorf("parse error at %s: %s", d.Start, d.Message)
+				}
+			}
+			checked = len(p.diagnostics)
+			return nil
+		}
+
+		_, stopped := p.parseNodesFunc(func(n *Node) bool {
+			if err := firstError(); err != nil {
+				yield(nil, err)
+				return false
+			}
+			return yield(n, nil)
+		})
+		if stopped {
+			return
+		}
+
+		p.expect(tokenEOF)
+		if err := firstError(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// ParseAll parses a stream of KDL documents read from r and returns them in
+// order. Documents are separated by delimiter lines: lines consisting of a
+// single form feed character (U+000C), optionally followed by a carriage
+// return, ending in a line feed or the end of the input. For example:
+//
+//	snapshot 1
+//	\f
+//	snapshot 2
+//
+// holds two documents. The delimiter lines themselves belong to neither
+// document. n delimiters separate n+1 documents, which may be empty, except
+// that a delimiter at the very end of the input does not begin another
+// document, and an empty input holds no documents. Note that a form feed on a
+// line of its own within a multi-line string is also treated as a delimiter.
+//
+// Each document is parsed independently, as if by [Parse] with the given
+// options, so each detects its own KDL version and locations are relative to
+// the start of the document. If a document is not valid KDL, ParseAll returns
+// an error naming its zero-based index.
+func ParseAll(r io.Reader, opts ...ParseOption) ([]*Document, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var docs []*Document
+	for i, part := range splitDocuments(src) {
+		doc, err := Parse(bytes.NewReader(part), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("kdl.ParseAll: document %d: %w", i, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// splitDocuments splits src at the delimiter lines described in [ParseAll].
+func splitDocuments(src []byte) [][]byte {
+	var parts [][]byte
+	start := 0
+	for i := 0; i < len(src); {
+		next := len(src)
+		line := src[i:]
+		if j := bytes.IndexByte(line, '\n'); j >= 0 {
+			next = i + j + 1
+			line = line[:j]
+		}
+		if string(bytes.TrimSuffix(line, []byte("\r"))) == "\f" {
+			parts = append(parts, src[start:i])
+			start = next
+		}
+		i = next
+	}
+	if start < len(src) {
+		parts = append(parts, src[start:])
+	}
+	return parts
+}
+
+// An EventHandler receives the contents of a KDL document from [ParseEvents]
+// as a sequence of events. If a method returns a non-nil error, parsing stops
+// and ParseEvents returns that error.
+type EventHandler interface {
+	// StartNode begins a node. ok reports whether the node has a type
+	// annotation, and if so, typeAnnotation holds it.
+	StartNode(name, typeAnnotation string, ok bool) error
+	// Argument reports an argument of the current node.
+	Argument(v Value) error
+	// Property reports a property of the current node.
+	Property(key string, v Value) error
+	// EndNode ends the current node.
+	EndNode() error
+}
+
+// ParseEvents parses the KDL document read from r and reports its contents to
+// h, without building a [Document]. It is built on [ParseNodes] and shares its
+// limits: the whole input is read into memory before parsing begins, each
+// top-level node is parsed in full (with all of its descendants) before any of
+// its events are delivered, and the input is parsed as [Version2] unless
+// another version is given with [WithVersion], since the version cannot be
+// detected as [Parse] does. Nodes that have already been reported are not
+// retained.
+//
+// Events are delivered in document order. Each node produces a StartNode
+// event, followed by its arguments and properties in the order they appear in
+// the source, followed by the events for its children, followed by EndNode.
+// Every property occurrence is reported, so a node with a duplicate key
+// produces more than one Property event for it; the last one is the value
+// [Node.Prop] would return. Slashdashed entries and nodes, comments, and
+// formatting are not reported.
+//
+// Events for a top-level node are only delivered once the whole node has been
+// parsed. If the input is not valid KDL, h receives complete events for the
+// top-level nodes preceding the first error, no events for the node containing
+// it, and ParseEvents returns the error.
+func ParseEvents(r io.Reader, h EventHandler, opts ...ParseOption) error {
+	for n, err := range ParseNodes(r, opts...) {
+		if err != nil {
+			return err
+		}
+		if err := emitEvents(h, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func emitEvents(h EventHandler, n *Node) error {
+	if err := h.StartNode(n.name, n.typ, n.typeValid); err != nil {
+		return err
+	}
+	args, props := 0, 0
+	for _, kind := range n.entries {
+		var err error
+		if kind == nodeEntryArg {
+			err = h.Argument(n.args[args])
+			args++
+		} else {
+			err = h.Property(n.propEntries[props].key, n.propEntries[props].value)
+			props++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, child := range n.children.Nodes {
+		if err := emitEvents(h, child); err != nil {
+			return err
+		}
+	}
+	return h.EndNode()
+}
@@ -0,0 +1,205 @@
+package kdl
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseNodes(t *testing.T) {
+	src := "// leading\na 1\nb { child }\nc key=#true // trailing\n"
+
+	var names []string
+	for node, err := range ParseNodes(strings.NewReader(src)) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		names = append(names, node.Name())
+		switch node.Name() {
+		case "a":
+			if len(node.LeadingComments()) != 1 {
+				t.Errorf("expected leading comment on a, got %v", node.LeadingComments())
+			}
+		case "b":
+			if len(node.Children().Nodes) != 1 {
+				t.Errorf("expected one child on b, got %d", len(node.Children().Nodes))
+			}
+		case "c":
+			if _, ok := node.TrailingComment(); !ok {
+				t.Errorf("expected trailing comment on c")
+			}
+		}
+	}
+	if got := strings.Join(names, ","); got != "a,b,c" {
+		t.Errorf("got nodes %s, want a,b,c", got)
+	}
+}
+
+func TestParseNodesError(t *testing.T) {
+	src := "a\nb\nc \"unterminated\nd\n"
+
+	var names []string
+	var gotErr error
+	for node, err := range ParseNodes(strings.NewReader(src)) {
+		if err != nil {
+			gotErr = err
+			break
+		}
+		names = append(names, node.Name())
+	}
+	if gotErr == nil {
+		t.Fatal("expected an error")
+	}
+	if got := strings.Join(names, ","); got != "a,b" {
+		t.Errorf("got nodes %s before error, want a,b", got)
+	}
+}
+
+func TestParseNodesStopEarly(t *testing.T) {
+	count := 0
+	for range ParseNodes(strings.NewReader("a\nb\nc\n")) {
+		count++
+		if count == 2 {
+			break
+		}
+	}
+	if count != 2 {
+		t.Errorf("got %d nodes, want 2", count)
+	}
+}
+
+func TestParseNodesVersion(t *testing.T) {
+	var args []Value
+	for node, err := range ParseNodes(strings.NewReader("node true null\n"), WithVersion(Version1)) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		args = node.Arguments()
+	}
+	if len(args) != 2 || args[0].Kind() != Bool || args[1].Kind() != Null {
+		t.Errorf("unexpected v1 arguments: %v", args)
+	}
+}
+
+type recordingHandler struct {
+	events []string
+	stopAt string
+}
+
+func (h *recordingHandler) record(event string) error {
+	h.events = append(h.events, event)
+	if event == h.stopAt {
+		return errors.New("stop")
+	}
+	return nil
+}
+
+func (h *recordingHandler) StartNode(name, typeAnnotation string, ok bool) error {
+	if ok {
+		return h.record("start (" + typeAnnotation + ")" + name)
+	}
+	return h.record("start " + name)
+}
+
+func (h *recordingHandler) Argument(v Value) error {
+	return h.record("arg " + strconv.Itoa(v.Int()))
+}
+
+func (h *recordingHandler) Property(key string, v Value) error {
+	return h.record("prop " + key + "=" + strconv.Itoa(v.Int()))
+}
+
+func (h *recordingHandler) EndNode() error {
+	return h.record("end")
+}
+
+func TestParseEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		stopAt  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "nested",
+			src:  "(t)a 1 k=2 3 /-4 {\n  b k=1 k=2\n}\nc\n",
+			want: []string{
+				"start (t)a", "arg 1", "prop k=2", "arg 3",
+				"start b", "prop k=1", "prop k=2", "end",
+				"end",
+				"start c", "end",
+			},
+		},
+		{
+			name:    "parse error",
+			src:     "a\nb { c \"unterminated\n}\n",
+			want:    []string{"start a", "end"},
+			wantErr: true,
+		},
+		{
+			name:    "handler error",
+			src:     "a 1 2\nb\n",
+			stopAt:  "arg 1",
+			want:    []string{"start a", "arg 1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &recordingHandler{stopAt: tt.stopAt}
+			err := ParseEvents(strings.NewReader(tt.src), h)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := strings.Join(h.events, "; "), strings.Join(tt.want, "; "); got != want {
+				t.Errorf("got events\n\t%s\nwant\n\t%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		want  []string // node names per document
+		error string
+	}{
+		{"single", "a\nb\n", []string{"a,b"}, ""},
+		{"several", "a\n\f\nb\r\n\f\r\nc\n\f\n", []string{"a", "b", "c"}, ""},
+		{"empty documents", "\f\n\f\na", []string{"", "", "a"}, ""},
+		{"no delimiter at end of line", "a \f\nb\n", []string{"a,b"}, ""},
+		{"empty input", "", nil, ""},
+		{"versions", "a true\n\f\nb #true\n", []string{"a", "b"}, ""},
+		{"bad document", "a\n\f\nb\n\f\nc {\n", nil, "document 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := ParseAll(strings.NewReader(tt.src))
+			if tt.error != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.error) {
+					t.Fatalf("got error %v, want error mentioning %q", err, tt.error)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, doc := range docs {
+				var names []string
+				for _, n := range doc.Nodes {
+					names = append(names, n.Name())
+				}
+				got = append(got, strings.Join(names, ","))
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("got documents %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
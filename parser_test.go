@@ -1,6 +1,12 @@
 package kdl
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
 
 func TestParseWithSourceName(t *testing.T) {
 	result := ParseStringWithDiagnostics("node", WithSourceName("test.kdl"))
@@ -27,3 +33,206 @@ func TestParseWithSourceName(t *testing.T) {
 		t.Errorf("expected diagnostic source name 'test.kdl', got '%s'", result.Diagnostics[0].Start.Filename)
 	}
 }
+
+// countdownContext reports itself as cancelled once Err has been called n
+// times, so cancellation can be triggered partway through a parse.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestParseContext(t *testing.T) {
+	src := strings.Repeat("node 1 2 3\n", 100)
+
+	doc, err := ParseContext(context.Background(), strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(doc.Nodes) != 100 {
+		t.Errorf("got %d nodes, want 100", len(doc.Nodes))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ParseContext(ctx, strings.NewReader(src)); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+
+	ctx = &countdownContext{Context: context.Background(), n: 10}
+	if _, err := ParseContext(ctx, strings.NewReader(src)); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v after cancellation mid-parse, want context.Canceled", err)
+	}
+}
+
+func TestParseMaxDepth(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("n {", depth) + strings.Repeat("}", depth)
+	}
+
+	if _, err := ParseString(nested(DefaultMaxDepth)); err != nil {
+		t.Errorf("unexpected error at default max depth: %v", err)
+	}
+
+	_, err := ParseString(nested(100_000))
+	if err == nil || !strings.Contains(err.Error(), "max nesting depth of 1000 exceeded") {
+		t.Errorf("got error %v, want max nesting depth error", err)
+	}
+
+	result := ParseStringWithDiagnostics(nested(5), WithVersion(Version2), WithMaxDepth(3))
+	var codes []string
+	for _, d := range result.Diagnostics {
+		codes = append(codes, d.Code)
+	}
+	if len(codes) != 1 || codes[0] != DiagParseMaxDepthExceeded {
+		t.Errorf("got diagnostics %v, want a single %s", codes, DiagParseMaxDepthExceeded)
+	}
+
+	if _, err := ParseString(nested(DefaultMaxDepth+1), WithMaxDepth(0)); err != nil {
+		t.Errorf("unexpected error with limit disabled: %v", err)
+	}
+}
+
+// dataEOFReader returns all of its remaining data together with io.EOF in a
+// single Read call, which io.Reader permits.
+type dataEOFReader struct{ data []byte }
+
+func (r *dataEOFReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	if len(r.data) == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestParseReaderDataWithEOF(t *testing.T) {
+	doc, err := Parse(&dataEOFReader{data: []byte("a 1\nb 2\n")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(doc.Nodes) != 2 || doc.Nodes[1].Name() != "b" {
+		t.Errorf("got %d nodes, want a and b", len(doc.Nodes))
+	}
+}
+
+func TestParseNodeTypeAnnotation(t *testing.T) {
+	tests := []struct {
+		src     string
+		version Version
+		typ     string
+	}{
+		{`(author)person name="x"`, Version2, "author"},
+		{`(author)person "arg" { child; }`, Version2, "author"},
+		{`("quoted type")person`, Version2, "quoted type"},
+		{`(author)"quoted name"`, Version2, "author"},
+		{`( author )person`, Version2, "author"},
+		{`(author)person name="x"`, Version1, "author"},
+		{"/- (skipped)node\n(author)person", Version2, "author"},
+	}
+	for _, tt := range tests {
+		doc, err := ParseString(tt.src, WithVersion(tt.version))
+		if err != nil {
+			t.Errorf("ParseString(%q) error = %v", tt.src, err)
+			continue
+		}
+		if len(doc.Nodes) != 1 {
+			t.Errorf("ParseString(%q) = %d nodes, want 1", tt.src, len(doc.Nodes))
+			continue
+		}
+		if ty, ok := doc.Nodes[0].TypeAnnotation(); !ok || ty != tt.typ {
+			t.Errorf("ParseString(%q) type annotation = %q, %v; want %q, true", tt.src, ty, ok, tt.typ)
+		}
+	}
+
+	doc, err := ParseString("person { (author)child; plain; }")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := doc.Nodes[0].TypeAnnotation(); ok {
+		t.Error("unannotated parent has a type annotation")
+	}
+	children := doc.Nodes[0].Children().Nodes
+	if ty, ok := children[0].TypeAnnotation(); !ok || ty != "author" {
+		t.Errorf("child type annotation = %q, %v; want author, true", ty, ok)
+	}
+	if _, ok := children[1].TypeAnnotation(); ok {
+		t.Error("unannotated child has a type annotation")
+	}
+}
+
+func TestParseVersionMismatch(t *testing.T) {
+	tests := []struct {
+		src       string
+		requested Version
+		suggested Version
+	}{
+		{"node #true", Version1, Version2},
+		{"node true", Version2, Version1},
+		{"node {", Version1, VersionAuto},
+		{"node {", Version2, VersionAuto},
+	}
+	for _, tt := range tests {
+		_, err := ParseString(tt.src, WithVersion(tt.requested))
+		if err == nil {
+			t.Errorf("%q at %s: expected error", tt.src, tt.requested)
+			continue
+		}
+		var mismatch *VersionMismatchError
+		if tt.suggested == VersionAuto {
+			if errors.Is(err, ErrVersionMismatch) || errors.As(err, &mismatch) {
+				t.Errorf("%q at %s: unexpected version mismatch: %v", tt.src, tt.requested, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrVersionMismatch) {
+			t.Errorf("%q at %s: got error %v, want ErrVersionMismatch", tt.src, tt.requested, err)
+		}
+		if !errors.As(err, &mismatch) {
+			t.Errorf("%q at %s: got error %T, want *VersionMismatchError", tt.src, tt.requested, err)
+		} else if mismatch.Requested != tt.requested || mismatch.Suggested != tt.suggested {
+			t.Errorf("%q at %s: got requested %s, suggested %s; want suggested %s", tt.src, tt.requested, mismatch.Requested, mismatch.Suggested, tt.suggested)
+		}
+
+		result := ParseStringWithDiagnostics(tt.src, WithVersion(tt.requested))
+		if result.SuggestedVersion != tt.suggested {
+			t.Errorf("%q at %s: got SuggestedVersion %s, want %s", tt.src, tt.requested, result.SuggestedVersion, tt.suggested)
+		}
+	}
+
+	if _, err := ParseString("node #true"); err != nil {
+		t.Errorf("unexpected error without explicit version: %v", err)
+	}
+}
+
+func TestParseSignedRadixIntegers(t *testing.T) {
+	tests := []struct {
+		src  string
+		want int
+	}{
+		{"n 0xff", 255},
+		{"n -0xff", -255},
+		{"n +0xff", 255},
+		{"n -0o17", -15},
+		{"n -0b1_01", -5},
+	}
+	for _, tt := range tests {
+		for _, v := range []Version{Version1, Version2} {
+			doc, err := ParseString(tt.src, WithVersion(v))
+			if err != nil {
+				t.Errorf("%q at %s: unexpected error: %v", tt.src, v, err)
+				continue
+			}
+			if got := doc.Nodes[0].Arg(0).Int(); got != tt.want {
+				t.Errorf("%q at %s: got %d, want %d", tt.src, v, got, tt.want)
+			}
+		}
+	}
+}
@@ -7,6 +7,10 @@ import (
 
 // A Printer formats a KDL document as an S-expression.
 type Printer struct {
+	// Compact prints the S-expression on a single line, separating nested
+	// expressions with spaces instead of newlines and indentation.
+	Compact bool
+
 	builder     strings.Builder
 	indent      int
 	atLineStart bool
@@ -23,11 +27,20 @@ func NewPrinter() *Printer {
 	return &Printer{}
 }
 
+// NewCompactPrinter returns a Printer with Compact set.
+func NewCompactPrinter() *Printer {
+	return &Printer{Compact: true}
+}
+
 func (p *Printer) String() string {
 	return p.builder.String()
 }
 
 func (p *Printer) print(s string) {
+	if p.Compact {
+		p.builder.WriteString(strings.ReplaceAll(s, "\n", " "))
+		return
+	}
 	lines := strings.Split(s, "\n")
 	for i, line := range lines {
 		if p.atLineStart {
@@ -61,10 +74,10 @@ func (p *Printer) PrintNode(node *Node) {
 		p.print("\n(node nil)")
 		return
 	}
-	p.printf("\n(node \"%s\"", node.name)
+	p.printf("\n(node %q", node.name)
 	p.indent++
 	if ty, ok := node.TypeAnnotation(); ok {
-		p.printf("\n(type \"%s\")", ty)
+		p.printf("\n(type %q)", ty)
 	}
 	for _, arg := range node.Arguments() {
 		p.print("\n(argument ")
@@ -72,7 +85,7 @@ func (p *Printer) PrintNode(node *Node) {
 		p.print(")")
 	}
 	for _, prop := range node.PropertyOrder() {
-		p.printf("\n(property \"%s\" ", prop)
+		p.printf("\n(property %q ", prop)
 		p.PrintValue(node.Properties()[prop])
 		p.print(")")
 	}
@@ -106,7 +119,7 @@ func (p *Printer) PrintValue(v Value) {
 	typeAnnot, ok := v.TypeAnnotation()
 	if ok {
 		p.indent++
-		p.printf("\n(type \"%s\")", typeAnnot)
+		p.printf("\n(type %q)", typeAnnot)
 		p.indent--
 	}
 	p.print(")")
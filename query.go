@@ -0,0 +1,505 @@
+package kdl
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query returns the nodes in doc matched by selector, in document order. The
+// selector syntax is a practical subset of the [KQL] query language:
+//
+//	a              nodes named a, at any depth
+//	"a b"          names may be quoted strings
+//	(t)a           nodes named a with type annotation t; () matches any annotation
+//	[]             any node
+//	a b            b nodes that are descendants of a nodes
+//	a > b          b nodes that are direct children of a nodes
+//	a, b           nodes matched by either selector
+//
+// A node filter may be followed by any number of matchers in brackets, all of
+// which must match:
+//
+//	[key]          the node has a property named key; prop(key) is equivalent
+//	[val(1)]       the node has an argument at index 1
+//	[key op v]     the property compares to the KDL value v
+//	[val() op v]   the first argument compares to v; val(N) uses index N
+//	[args() op n]  the number of arguments compares to the integer n
+//
+// Supported comparison operators are = and != (for any value), <, <=, >, and
+// >= (for numbers), and ^=, $=, and *= (for strings: prefix, suffix, and
+// substring). Type annotations on values are ignored when comparing. The
+// args() matcher is an extension that is not part of KQL.
+//
+// Other KQL syntax, such as the sibling combinators + and ~, top(), name(),
+// and tag(), is not supported; Query returns an error for it rather than
+// silently matching nothing.
+//
+// [KQL]: https://github.com/kdl-org/kdl/blob/main/QUERY-SPEC.md
+func Query(doc *Document, selector string) ([]*Node, error) {
+	alternatives, err := parseQuery(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	// record document order so results from different alternatives and
+	// branches can be merged
+	order := map[*Node]int{}
+	var all []*Node
+	Walk(doc, func(n *Node, _ int) bool {
+		order[n] = len(all)
+		all = append(all, n)
+		return true
+	})
+
+	seen := map[*Node]bool{}
+	var result []*Node
+	for _, steps := range alternatives {
+		var current []*Node
+		for _, n := range all {
+			if steps[0].filter.match(n) {
+				current = append(current, n)
+			}
+		}
+		for _, step := range steps[1:] {
+			current = step.apply(current)
+		}
+		for _, n := range current {
+			if !seen[n] {
+				seen[n] = true
+				result = append(result, n)
+			}
+		}
+	}
+
+	slices.SortFunc(result, func(a, b *Node) int { return order[a] - order[b] })
+	return result, nil
+}
+
+type queryCombinator uint8
+
+const (
+	queryDescendant queryCombinator = iota
+	queryChild
+)
+
+type queryStep struct {
+	combinator queryCombinator // how this step relates to the previous one
+	filter     queryFilter
+}
+
+// apply returns the nodes related to nodes by the step's combinator that match
+// its filter, without duplicates.
+func (s queryStep) apply(nodes []*Node) []*Node {
+	seen := map[*Node]bool{}
+	var out []*Node
+	var visit func(children []*Node)
+	visit = func(children []*Node) {
+		for _, c := range children {
+			if !seen[c] && s.filter.match(c) {
+				seen[c] = true
+				out = append(out, c)
+			}
+			if s.combinator == queryDescendant {
+				visit(c.children.Nodes)
+			}
+		}
+	}
+	for _, n := range nodes {
+		visit(n.children.Nodes)
+	}
+	return out
+}
+
+type queryFilter struct {
+	name     string
+	hasName  bool
+	typ      string
+	hasType  bool
+	matchers []queryMatcher
+}
+
+func (f queryFilter) match(n *Node) bool {
+	if f.hasName && n.name != f.name {
+		return false
+	}
+	if f.hasType && (!n.typeValid || (f.typ != "" && n.typ != f.typ)) {
+		return false
+	}
+	for _, m := range f.matchers {
+		if !m.match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+type queryMatcherKind uint8
+
+const (
+	queryMatchProp queryMatcherKind = iota
+	queryMatchArg
+	queryMatchArgCount
+)
+
+type queryMatcher struct {
+	kind  queryMatcherKind
+	key   string // property key for queryMatchProp
+	index int    // argument index for queryMatchArg
+	op    string // empty for an existence check
+	value Value
+}
+
+func (m queryMatcher) match(n *Node) bool {
+	var v Value
+	switch m.kind {
+	case queryMatchProp:
+		var ok bool
+		if v, ok = n.props[m.key]; !ok {
+			return false
+		}
+	case queryMatchArg:
+		if m.index >= len(n.args) {
+			return false
+		}
+		v = n.args[m.index]
+	case queryMatchArgCount:
+		v = NewInt(len(n.args))
+	}
+	if m.op == "" {
+		return true
+	}
+	return compareQueryValues(v, m.op, m.value)
+}
+
+func compareQueryValues(a Value, op string, b Value) bool {
+	switch op {
+	case "=":
+		return ValuesEqualIgnoreType(a, b)
+	case "!=":
+		return !ValuesEqualIgnoreType(a, b)
+	case "^=", "$=", "*=":
+		if a.kind != String || b.kind != String {
+			return false
+		}
+		switch op {
+		case "^=":
+			return strings.HasPrefix(a.String(), b.String())
+		case "$=":
+			return strings.HasSuffix(a.String(), b.String())
+		default:
+			return strings.Contains(a.String(), b.String())
+		}
+	}
+
+	fa, fb := queryNumber(a), queryNumber(b)
+	if fa == nil || fb == nil {
+		return false
+	}
+	c := fa.Cmp(fb)
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default: // ">="
+		return c >= 0
+	}
+}
+
+// queryNumber returns v as a big.Float, or nil if v is not a number (or is
+// NaN).
+func queryNumber(v Value) *big.Float {
+	switch v.kind {
+	case Int, BigInt:
+		return new(big.Float).SetInt(toBigInt(v))
+	case Float, BigFloat:
+		return toBigFloat(v)
+	}
+	return nil
+}
+
+// ======================== selector parsing ========================
+
+type queryParser struct {
+	src []rune
+	pos int
+}
+
+func parseQuery(selector string) ([][]queryStep, error) {
+	p := &queryParser{src: []rune(selector)}
+	var alternatives [][]queryStep
+	for {
+		steps, err := p.parseSelector()
+		if err != nil {
+			return nil, fmt.Errorf("kdl query: %w", err)
+		}
+		alternatives = append(alternatives, steps)
+		if p.eof() {
+			return alternatives, nil
+		}
+		p.pos++ // consume ','
+	}
+}
+
+func (p *queryParser) eof() bool { return p.pos >= len(p.src) }
+
+// peek returns the current rune, or 0 at the end of input.
+func (p *queryParser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *queryParser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// parseSelector parses one comma-separated alternative, stopping before the
+// ',' or at the end of input.
+func (p *queryParser) parseSelector() ([]queryStep, error) {
+	var steps []queryStep
+	p.skipSpace()
+	combinator := queryDescendant
+	for {
+		filter, err := p.parseFilter()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, queryStep{combinator: combinator, filter: filter})
+
+		spaced := p.skipSpace()
+		if p.eof() || p.peek() == ',' {
+			return steps, nil
+		}
+		switch p.peek() {
+		case '>':
+			p.pos++
+			p.skipSpace()
+			combinator = queryChild
+		case '+', '~':
+			return nil, p.errorf("sibling combinator %q is not supported", p.peek())
+		default:
+			if !spaced {
+				return nil, p.errorf("unexpected %q", p.peek())
+			}
+			combinator = queryDescendant
+		}
+	}
+}
+
+func (p *queryParser) parseFilter() (queryFilter, error) {
+	var f queryFilter
+	start := p.pos
+
+	if !p.eof() && p.peek() == '(' {
+		p.pos++
+		f.hasType = true
+		if !p.eof() && p.peek() != ')' {
+			typ, err := p.parseString()
+			if err != nil {
+				return f, err
+			}
+			f.typ = typ
+		}
+		if p.eof() || p.peek() != ')' {
+			return f, p.errorf("expected ')'")
+		}
+		p.pos++
+	}
+
+	if p.peek() == '"' || (!p.eof() && !isQuerySpecial(p.peek())) {
+		name, err := p.parseString()
+		if err != nil {
+			return f, err
+		}
+		if p.peek() == '(' {
+			return f, p.errorf("%s() is not supported", name)
+		}
+		f.name, f.hasName = name, true
+	}
+
+	for !p.eof() && p.peek() == '[' {
+		p.pos++
+		m, ok, err := p.parseMatcher()
+		if err != nil {
+			return f, err
+		}
+		if ok {
+			f.matchers = append(f.matchers, m)
+		}
+	}
+
+	if p.pos == start {
+		if p.eof() {
+			return f, p.errorf("expected a node filter, got end of selector")
+		}
+		return f, p.errorf("expected a node filter, got %q", p.peek())
+	}
+	return f, nil
+}
+
+// parseMatcher parses the contents of a [...] matcher after the '['. ok is
+// false for the empty matcher [].
+func (p *queryParser) parseMatcher() (m queryMatcher, ok bool, err error) {
+	p.skipSpace()
+	if !p.eof() && p.peek() == ']' {
+		p.pos++
+		return m, false, nil
+	}
+
+	quoted := !p.eof() && p.peek() == '"'
+	lhs, err := p.parseString()
+	if err != nil {
+		return m, false, err
+	}
+	m.kind, m.key = queryMatchProp, lhs
+	if !quoted && p.peek() == '(' {
+		p.pos++
+		arg := p.readUntil(')')
+		if p.eof() {
+			return m, false, p.errorf("expected ')'")
+		}
+		p.pos++
+		switch lhs {
+		case "prop":
+			if m.key, err = unquoteQueryString(strings.TrimSpace(arg)); err != nil || m.key == "" {
+				return m, false, p.errorf("invalid property name %q", arg)
+			}
+		case "val":
+			m.kind = queryMatchArg
+			if arg = strings.TrimSpace(arg); arg != "" {
+				if m.index, err = strconv.Atoi(arg); err != nil || m.index < 0 {
+					return m, false, p.errorf("invalid argument index %q", arg)
+				}
+			}
+		case "args":
+			m.kind = queryMatchArgCount
+			if strings.TrimSpace(arg) != "" {
+				return m, false, p.errorf("args() takes no arguments")
+			}
+		default:
+			return m, false, p.errorf("%s() is not supported", lhs)
+		}
+	}
+
+	p.skipSpace()
+	if p.eof() {
+		return m, false, p.errorf("expected ']'")
+	}
+	if p.peek() == ']' {
+		p.pos++
+		if m.kind == queryMatchArgCount {
+			return m, false, p.errorf("args() requires a comparison")
+		}
+		return m, true, nil
+	}
+
+	for _, op := range []string{"!=", "<=", ">=", "^=", "$=", "*=", "=", "<", ">"} {
+		if strings.HasPrefix(string(p.src[p.pos:]), op) {
+			m.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if m.op == "" {
+		return m, false, p.errorf("expected comparison operator, got %q", p.peek())
+	}
+
+	p.skipSpace()
+	text := strings.TrimSpace(p.readUntil(']'))
+	if p.eof() {
+		return m, false, p.errorf("expected ']'")
+	}
+	p.pos++
+	doc, err := ParseString("_ "+text, WithVersion(Version2))
+	if err != nil || len(doc.Nodes) != 1 || len(doc.Nodes[0].args) != 1 || len(doc.Nodes[0].props) != 0 || len(doc.Nodes[0].children.Nodes) != 0 {
+		return m, false, p.errorf("invalid value %q", text)
+	}
+	m.value = doc.Nodes[0].args[0]
+	if m.kind == queryMatchArgCount && m.value.kind != Int {
+		return m, false, p.errorf("args() must be compared to an integer, got %q", text)
+	}
+	return m, true, nil
+}
+
+// readUntil returns the text up to (but not including) the first unquoted
+// occurrence of end, leaving the parser positioned on it.
+func (p *queryParser) readUntil(end rune) string {
+	start := p.pos
+	inQuote := false
+	for ; !p.eof(); p.pos++ {
+		ch := p.peek()
+		switch {
+		case inQuote && ch == '\\':
+			p.pos++
+		case ch == '"':
+			inQuote = !inQuote
+		case !inQuote && ch == end:
+			return string(p.src[start:p.pos])
+		}
+	}
+	return string(p.src[start:])
+}
+
+// parseString parses a quoted string or a bare identifier.
+func (p *queryParser) parseString() (string, error) {
+	if p.eof() {
+		return "", p.errorf("expected identifier or string, got end of selector")
+	}
+	if p.peek() == '"' {
+		start := p.pos
+		p.pos++
+		for !p.eof() && p.peek() != '"' {
+			if p.peek() == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		text := string(p.src[start+1 : p.pos])
+		p.pos++
+		s, err := unescapeString(text, Version2)
+		if err != nil {
+			p.pos = start
+			return "", p.errorf("%v", err)
+		}
+		return s, nil
+	}
+
+	start := p.pos
+	for !p.eof() && !unicode.IsSpace(p.peek()) && !isQuerySpecial(p.peek()) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected identifier or string, got %q", p.peek())
+	}
+	return string(p.src[start:p.pos]), nil
+}
+
+func unquoteQueryString(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return unescapeString(s[1:len(s)-1], Version2)
+	}
+	return s, nil
+}
+
+func isQuerySpecial(ch rune) bool {
+	return strings.ContainsRune(`[]()>+~,="!<^$*`, ch)
+}
@@ -0,0 +1,109 @@
+package kdl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	doc, err := ParseString(`
+host web port=80 {
+    alias www
+    alias "web.local"
+}
+host ssh port=22 user=root {
+    (tag)alias shell
+}
+(legacy)host old port=2222
+group {
+    host inner port=22 {
+        host nested
+    }
+}
+`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	names := func(nodes []*Node) string {
+		var out []string
+		for _, n := range nodes {
+			label := n.Name()
+			if v := n.Arg(0); v.Kind() == String {
+				label += ":" + v.String()
+			}
+			out = append(out, label)
+		}
+		return strings.Join(out, " ")
+	}
+
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{"host", "host:web host:ssh host:old host:inner host:nested"},
+		{`"host"`, "host:web host:ssh host:old host:inner host:nested"},
+		{"group > host", "host:inner"},
+		{"group host", "host:inner host:nested"},
+		{"host > alias", "alias:www alias:web.local alias:shell"},
+		{"host[port=22]", "host:ssh host:inner"},
+		{"host[port = 22]", "host:ssh host:inner"},
+		{"host[port!=22]", "host:web host:old"},
+		{"host[port>=80]", "host:web host:old"},
+		{"host[port<80]", "host:ssh host:inner"},
+		{"host[user]", "host:ssh"},
+		{"host[prop(user)=root]", "host:ssh"},
+		{"[port=22][user=root]", "host:ssh"},
+		{`host[val()="ssh"]`, "host:ssh"},
+		{"host[val()=ssh]", "host:ssh"},
+		{"host[val(1)]", ""},
+		{"[val()^=web]", "host:web alias:web.local"},
+		{"[val()$=local]", "alias:web.local"},
+		{"[val()*=ell]", "alias:shell"},
+		{"[args()=0]", "group"},
+		{"[args()>0] > [args()=0]", ""},
+		{"(legacy)host", "host:old"},
+		{"()alias", "alias:shell"},
+		{"(tag)[]", "alias:shell"},
+		{"group, host[port=80]", "host:web group"},
+		{"[]", "host:web alias:www alias:web.local host:ssh alias:shell host:old group host:inner host:nested"},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			nodes, err := Query(doc, tt.selector)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.selector, err)
+			}
+			if got := names(nodes); got != tt.want {
+				t.Errorf("Query(%q) = %q, want %q", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	doc := NewDocument(NewNode("a"))
+	for _, selector := range []string{
+		"",
+		"a,",
+		"a + b",
+		"a ~ b",
+		"top() > a",
+		"a[name()=a]",
+		"a[port",
+		"a[port=]",
+		"a[port=1 2]",
+		"a[port 1]",
+		"a[args()]",
+		"a[args()=x]",
+		"a[val(x)]",
+		`"unterminated`,
+		"(t",
+		"a>",
+	} {
+		if _, err := Query(doc, selector); err == nil {
+			t.Errorf("Query(%q) returned no error", selector)
+		}
+	}
+}
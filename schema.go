@@ -138,6 +138,13 @@ func ParseSchema(r io.Reader) (*Schema, error) {
 	return buildSchema(doc)
 }
 
+// LoadSchema builds a schema from an already parsed schema document, as
+// [ParseSchema] does after parsing. This allows a schema to be embedded in a
+// larger document or constructed programmatically.
+func LoadSchema(doc *Document) (*Schema, error) {
+	return buildSchema(doc)
+}
+
 // ParseSchemaFromFile reads and parses a KDL schema document from path.
 func ParseSchemaFromFile(path string) (*Schema, error) {
 	f, err := os.Open(path)
@@ -154,6 +161,46 @@ func ValidateDocument(doc *Document, schema *Schema) []Diagnostic {
 	return validateChildren(doc.Nodes, nil, nil, schema.Nodes, schema.OtherNodesAllowed, schema)
 }
 
+// Validate checks doc against the schema and returns every violation found,
+// or nil if the document conforms. This covers the same rules as
+// [ValidateDocument], including required and allowed nodes and properties,
+// value types, and argument counts, but only error diagnostics are reported.
+//
+// Each error is a [Diagnostic] (usable with [errors.As]) wrapped with the path
+// of the node it was found on, as returned by [Node.Path], when that node can
+// be determined from the diagnostic's location.
+func (s *Schema) Validate(doc *Document) []error {
+	var errs []error
+	for _, d := range ValidateDocument(doc, s) {
+		if d.Severity != SeverityError {
+			continue
+		}
+		if n := nodeAtLocation(doc, d.Start); n != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n.Path(), d))
+		} else {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+// nodeAtLocation returns the innermost node of doc whose source range contains
+// loc, or nil if there is none or loc is not a real location.
+func nodeAtLocation(doc *Document, loc Location) *Node {
+	if loc.Line == 0 {
+		return nil
+	}
+	var found *Node
+	Walk(doc, func(n *Node, _ int) bool {
+		if n.loc.Line == 0 || loc.Offset < n.loc.Offset || loc.Offset >= n.endLoc.Offset {
+			return false
+		}
+		found = n
+		return true
+	})
+	return found
+}
+
 // addRelated appends a DiagnosticRelated to d if start has a real location.
 func addRelated(d *Diagnostic, start, end Location, msg string) {
 	if start.Line == 0 {
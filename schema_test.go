@@ -1,6 +1,7 @@
 package kdl_test
 
 import (
+	"errors"
 	"os"
 	"strings"
 	"testing"
@@ -705,3 +706,63 @@ document {
 		t.Error("expected type error for boolean when only string/number allowed")
 	}
 }
+
+func TestSchemaValidate(t *testing.T) {
+	schemaDoc := parseTestDoc(t, `
+document {
+    node "server" {
+        prop "name" {
+            required #true
+            type string
+        }
+        value {
+            min 1
+            max 1
+            type number
+        }
+        children {
+            node "route" {
+                prop "path" {
+                    required #true
+                    type string
+                }
+            }
+            other-nodes-allowed #false
+        }
+    }
+    other-nodes-allowed #false
+}`)
+	s, err := kdl.LoadSchema(schemaDoc)
+	if err != nil {
+		t.Fatalf("LoadSchema: %v", err)
+	}
+
+	if errs := s.Validate(parseTestDoc(t, `server 80 name=main { route path="/"; }`)); errs != nil {
+		t.Errorf("Validate() = %v, want nil", errs)
+	}
+
+	errs := s.Validate(parseTestDoc(t, `
+server 80 {
+    route
+    handler
+}
+client
+`))
+	want := []string{
+		`server: <input>:2:1: node "server" missing required property "name"`,
+		`server/route: <input>:3:5: node "route" missing required property "path"`,
+		`server/handler: <input>:4:5: unexpected node "handler"`,
+		`client: <input>:6:1: unexpected node "client"`,
+	}
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+		var d kdl.Diagnostic
+		if !errors.As(err, &d) {
+			t.Errorf("Validate() error %v is not a Diagnostic", err)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
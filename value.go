@@ -1,9 +1,11 @@
 package kdl
 
 import (
+	"encoding/base64"
 	"fmt"
 	"math"
 	"math/big"
+	"time"
 )
 
 type ValueKind uint8
@@ -79,6 +81,9 @@ type valueSourceInfo struct {
 	// (quoted, raw, or multi-line, including delimiters). Empty for
 	// programmatically created values.
 	literal string
+	// intFormat is the format set with WithIntegerFormat, if intFormatSet.
+	intFormat    IntegerFormat
+	intFormatSet bool
 }
 
 // IsValid reports whether this Value is valid. Most functions never return
@@ -93,6 +98,14 @@ func (v Value) TypeAnnotation() (string, bool) { return v.typ, v.typeValid }
 func (v Value) Kind() ValueKind                { return v.kind }
 func (v Value) RawValue() any                  { return v.raw }
 
+// IsInteger reports whether v is a KDL integer, either an [Int] or a [BigInt].
+func (v Value) IsInteger() bool { return isIntKind(v.kind) }
+
+// IsFloat reports whether v is a KDL floating-point number, either a [Float] or
+// a [BigFloat]. A value written as 1 is an integer, not a float; 1.0 is a
+// float.
+func (v Value) IsFloat() bool { return isFloatKind(v.kind) }
+
 // Location returns the source location of the value token, not including any
 // type annotation. Returns a zero Location when location tracking is off.
 func (v Value) Location() Location {
@@ -112,6 +125,13 @@ func (v Value) EndLocation() Location {
 	return Location{}
 }
 
+// Span returns the byte range of the value token in the source file, not
+// including any type annotation. Returns a zero Span when location tracking is
+// off.
+func (v Value) Span() Span {
+	return Span{v.Location().Offset, v.EndLocation().Offset}
+}
+
 // TypeAnnotationRange returns the source range of the type annotation content
 // (the identifier inside the parentheses, not the parens themselves). ok is
 // false when no type annotation is present or location tracking is off.
@@ -122,12 +142,20 @@ func (v Value) TypeAnnotationRange() (start, end Location, ok bool) {
 	return v.src.typeAnnotStart, v.src.typeAnnotEnd, true
 }
 
+// WithTypeAnnotation returns a copy of v with the given type annotation. If
+// valid is false, the copy has no type annotation.
 func (v Value) WithTypeAnnotation(ty string, valid bool) Value {
 	v.typ = ty
 	v.typeValid = valid
 	return v
 }
 
+// Annotated returns a copy of v with the type annotation ty. It is shorthand
+// for v.WithTypeAnnotation(ty, true).
+func (v Value) Annotated(ty string) Value {
+	return v.WithTypeAnnotation(ty, true)
+}
+
 // Literal returns the original source text of the string/numeric literal, if
 // this value was produced by parsing a KDL document and the literal text
 // contained meaningful syntax that should be preserved when round-tripping
@@ -155,6 +183,21 @@ func (v Value) WithLiteral(s string) Value {
 	return v
 }
 
+// WithIntegerFormat returns a copy of v that is written in format f by [Emit]
+// and [Format], overriding [WithIntegerFormat] for this value only, e.g. to
+// write a bitmask as 0b1010. Any [Value.Literal] is discarded. For values that
+// are not an [Int] or [BigInt], the format is ignored.
+func (v Value) WithIntegerFormat(f IntegerFormat) Value {
+	var src valueSourceInfo
+	if v.src != nil {
+		src = *v.src
+	}
+	src.literal = ""
+	src.intFormat, src.intFormatSet = f, true
+	v.src = &src
+	return v
+}
+
 // String returns the underlying string value if this value is of kind [String].
 // Unlike the other typed accessor methods on Value, it does not panic on a kind
 // mismatch to safely implement [fmt.Stringer] for all kinds; instead it returns
@@ -244,6 +287,57 @@ func (v Value) Equal(other Value) bool {
 	}
 }
 
+// ValuesEqual reports whether a and b are equal in value. Unlike [Value.Equal],
+// it treats an [Int] and a [BigInt] with the same numeric value as equal, and
+// likewise for a [Float] and a [BigFloat]. Integers and floats are never equal
+// to each other. Type annotations must match; use [ValuesEqualIgnoreType] to
+// ignore them.
+func ValuesEqual(a, b Value) bool {
+	if a.typeValid != b.typeValid || (a.typeValid && a.typ != b.typ) {
+		return false
+	}
+	return ValuesEqualIgnoreType(a, b)
+}
+
+// ValuesEqualIgnoreType is like [ValuesEqual], but ignores type annotations.
+func ValuesEqualIgnoreType(a, b Value) bool {
+	switch {
+	case a.kind == b.kind:
+		a.typ, a.typeValid = b.typ, b.typeValid
+		return a.Equal(b)
+	case isIntKind(a.kind) && isIntKind(b.kind):
+		return toBigInt(a).Cmp(toBigInt(b)) == 0
+	case isFloatKind(a.kind) && isFloatKind(b.kind):
+		fa, fb := toBigFloat(a), toBigFloat(b)
+		return fa != nil && fb != nil && fa.Cmp(fb) == 0
+	default:
+		return false
+	}
+}
+
+func isIntKind(k ValueKind) bool   { return k == Int || k == BigInt }
+func isFloatKind(k ValueKind) bool { return k == Float || k == BigFloat }
+
+func toBigInt(v Value) *big.Int {
+	if v.kind == Int {
+		return big.NewInt(int64(v.raw.(int)))
+	}
+	return v.raw.(*big.Int)
+}
+
+// toBigFloat returns v as a big.Float, or nil if v is a NaN (which is not
+// equal to anything).
+func toBigFloat(v Value) *big.Float {
+	if v.kind == Float {
+		f := v.raw.(float64)
+		if math.IsNaN(f) {
+			return nil
+		}
+		return big.NewFloat(f)
+	}
+	return v.raw.(*big.Float)
+}
+
 // NewString creates a new KDL string Value.
 func NewString(s string) Value {
 	return Value{kind: String, raw: s}
@@ -288,11 +382,41 @@ func NewBool(b bool) Value {
 	}
 }
 
+// NewBytes creates a new KDL string Value holding the standard base64
+// encoding of b, with a (base64) type annotation. See [AsBytes] for the
+// inverse.
+func NewBytes(b []byte) Value {
+	return Value{kind: String, raw: base64.StdEncoding.EncodeToString(b), typ: "base64", typeValid: true}
+}
+
+// NewTime creates a new KDL string Value holding t formatted as
+// [time.RFC3339Nano], with the reserved (date-time) type annotation. See
+// [AsTime] and [AsRFC3339] for the inverse.
+func NewTime(t time.Time) Value {
+	return Value{kind: String, raw: t.Format(time.RFC3339Nano), typ: "date-time", typeValid: true}
+}
+
+// NewDuration creates a new KDL string Value holding d as an ISO 8601
+// duration, such as "PT1M30S", with the reserved (duration) type annotation.
+// The largest unit used is hours, since days and longer units vary in length.
+// See [AsDuration] for the inverse. Use [NewInt] to store a duration as a
+// number of nanoseconds instead.
+func NewDuration(d time.Duration) Value {
+	return Value{kind: String, raw: formatISODuration(d), typ: "duration", typeValid: true}
+}
+
 // NewNull creates a new KDL null Value.
 func NewNull() Value {
 	return nullValue
 }
 
+// NewNullAnnotated creates a new KDL null Value with the type annotation ty,
+// such as (maybe)#null. The other New* constructors can be annotated in the
+// same way with [Value.Annotated].
+func NewNullAnnotated(ty string) Value {
+	return nullValue.Annotated(ty)
+}
+
 // Internal predefined values for common constants.
 var (
 	nullValue   = Value{kind: Null, raw: nil}
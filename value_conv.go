@@ -0,0 +1,360 @@
+package kdl
+
+import (
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The As* functions convert a [Value] to a Go type, returning an error if the
+// value is not of a suitable kind or does not fit. Their signature matches the
+// conversion function accepted by [GetOr] and [GetKVOr].
+
+// AsUint64 returns the value of an [Int] or [BigInt] Value as a uint64. It
+// returns an error if v is of any other kind, is negative, or overflows uint64.
+func AsUint64(v Value) (uint64, error) {
+	switch v.kind {
+	case Int:
+		i := v.raw.(int)
+		if i < 0 {
+			return 0, fmt.Errorf("kdl.AsUint64: negative integer %d", i)
+		}
+		return uint64(i), nil
+	case BigInt:
+		bi := toBigInt(v)
+		if bi.Sign() < 0 {
+			return 0, fmt.Errorf("kdl.AsUint64: negative integer %s", bi)
+		}
+		if !bi.IsUint64() {
+			return 0, fmt.Errorf("kdl.AsUint64: integer %s overflows uint64", bi)
+		}
+		return bi.Uint64(), nil
+	default:
+		return 0, fmt.Errorf("kdl.AsUint64: expected an integer, got %s", v.kind)
+	}
+}
+
+// AsUint is like [AsUint64], but returns a uint, and also returns an error if
+// the value overflows uint.
+func AsUint(v Value) (uint, error) {
+	u, err := AsUint64(v)
+	if err != nil {
+		return 0, err
+	}
+	if u > math.MaxUint {
+		return 0, fmt.Errorf("kdl.AsUint: integer %d overflows uint", u)
+	}
+	return uint(u), nil
+}
+
+// AsNumber returns the value of an [Int], [Float], [BigInt], or [BigFloat]
+// Value as a float64, so that a number can be read whether it was written as
+// an integer or a float. It returns an error if v is of any other kind, if an
+// integer cannot be represented exactly as a float64, or if a [BigFloat]
+// overflows float64 or underflows to zero. Use [AsBigNumber] to convert without
+// loss.
+func AsNumber(v Value) (float64, error) {
+	switch v.kind {
+	case Float:
+		return v.raw.(float64), nil
+	case Int, BigInt:
+		f, acc := new(big.Float).SetInt(toBigInt(v)).Float64()
+		if acc != big.Exact {
+			return 0, fmt.Errorf("kdl.AsNumber: integer %s cannot be represented exactly as float64", toBigInt(v))
+		}
+		return f, nil
+	case BigFloat:
+		bf := v.raw.(*big.Float)
+		f, _ := bf.Float64()
+		if (math.IsInf(f, 0) && !bf.IsInf()) || (f == 0 && bf.Sign() != 0) {
+			return 0, fmt.Errorf("kdl.AsNumber: float %s does not fit in float64", bf.Text('g', 10))
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("kdl.AsNumber: expected a number, got %s", v.kind)
+	}
+}
+
+// AsBigNumber is like [AsNumber], but returns the number as a new *big.Float
+// without any loss of precision. It returns an error if v is not a number or is
+// a NaN, which *big.Float cannot represent.
+func AsBigNumber(v Value) (*big.Float, error) {
+	switch v.kind {
+	case Int, BigInt:
+		return new(big.Float).SetInt(toBigInt(v)), nil
+	case Float, BigFloat:
+		bf := toBigFloat(v)
+		if bf == nil {
+			return nil, fmt.Errorf("kdl.AsBigNumber: NaN is not representable")
+		}
+		return new(big.Float).Copy(bf), nil
+	default:
+		return nil, fmt.Errorf("kdl.AsBigNumber: expected a number, got %s", v.kind)
+	}
+}
+
+// AsDuration returns the value of a [String] Value parsed with
+// [time.ParseDuration], such as "30s" or "1h30m", or the value of an [Int]
+// Value as a number of nanoseconds. If the string has the reserved (duration)
+// type annotation, it is parsed as an ISO 8601 duration such as "PT1M30S"
+// first, as produced by [NewDuration]; years, months, and weeks are not
+// supported, and days are 24 hours. It returns an error if v is of any other
+// kind or cannot be parsed. Use [AsDurationUnit] to interpret integers in
+// another unit.
+func AsDuration(v Value) (time.Duration, error) {
+	return AsDurationUnit(time.Nanosecond)(v)
+}
+
+// AsDurationUnit returns a function like [AsDuration] that interprets [Int]
+// values as a number of unit, such as [time.Millisecond] or [time.Second].
+// The function returns an error if the resulting duration overflows.
+func AsDurationUnit(unit time.Duration) func(Value) (time.Duration, error) {
+	return func(v Value) (time.Duration, error) {
+		switch v.kind {
+		case String:
+			s := v.raw.(string)
+			if v.typeValid && v.typ == "duration" {
+				if d, ok := parseISODuration(s); ok {
+					return d, nil
+				}
+			}
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return 0, fmt.Errorf("kdl.AsDuration: invalid duration %q", s)
+			}
+			return d, nil
+		case Int:
+			i := int64(v.raw.(int))
+			if unit != 0 && (i > math.MaxInt64/int64(unit) || i < math.MinInt64/int64(unit)) {
+				return 0, fmt.Errorf("kdl.AsDuration: %d * %s overflows time.Duration", i, unit)
+			}
+			return time.Duration(i) * unit, nil
+		default:
+			return 0, fmt.Errorf("kdl.AsDuration: expected a string or integer, got %s", v.kind)
+		}
+	}
+}
+
+// formatISODuration formats d as an ISO 8601 duration using hours, minutes,
+// and (fractional) seconds.
+func formatISODuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+	var b []byte
+	u := uint64(d)
+	if d < 0 {
+		b = append(b, '-')
+		u = -u
+	}
+	b = append(b, "PT"...)
+	if h := u / uint64(time.Hour); h > 0 {
+		b = append(strconv.AppendUint(b, h, 10), 'H')
+		u -= h * uint64(time.Hour)
+	}
+	if m := u / uint64(time.Minute); m > 0 {
+		b = append(strconv.AppendUint(b, m, 10), 'M')
+		u -= m * uint64(time.Minute)
+	}
+	if u > 0 {
+		b = strconv.AppendUint(b, u/uint64(time.Second), 10)
+		if frac := u % uint64(time.Second); frac > 0 {
+			digits := strconv.AppendUint(nil, frac+uint64(time.Second), 10)[1:] // zero-padded to 9 digits
+			b = append(append(b, '.'), strings.TrimRight(string(digits), "0")...)
+		}
+		b = append(b, 'S')
+	}
+	return string(b)
+}
+
+// parseISODuration parses an ISO 8601 duration of the form
+// [-+]P[nD][T[nH][nM][n[.n]S]], reporting false if s is not of that form or
+// overflows.
+func parseISODuration(s string) (time.Duration, bool) {
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	s, ok := strings.CutPrefix(s, "P")
+	if !ok || s == "" {
+		return 0, false
+	}
+
+	limit := uint64(math.MaxInt64)
+	if neg {
+		limit++ // -limit is math.MinInt64
+	}
+	var total uint64
+	add := func(n, unit uint64) bool {
+		if n > (limit-total)/unit {
+			return false
+		}
+		total += n * unit
+		return true
+	}
+
+	inTime := false
+	units := "D"
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return 0, false
+			}
+			inTime, units = true, "HMS"
+			s = s[1:]
+			continue
+		}
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		n, err := strconv.ParseUint(s[:i], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		s = s[i:]
+
+		var frac string
+		if s != "" && (s[0] == '.' || s[0] == ',') {
+			i := 1
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				i++
+			}
+			if i == 1 || i > 10 {
+				return 0, false
+			}
+			frac, s = s[1:i], s[i:]
+		}
+
+		if s == "" {
+			return 0, false
+		}
+		j := strings.IndexByte(units, s[0])
+		if j < 0 || (frac != "" && s[0] != 'S') {
+			return 0, false
+		}
+		units = units[j+1:]
+
+		var unit uint64
+		switch s[0] {
+		case 'D':
+			unit = uint64(24 * time.Hour)
+		case 'H':
+			unit = uint64(time.Hour)
+		case 'M':
+			unit = uint64(time.Minute)
+		case 'S':
+			unit = uint64(time.Second)
+		}
+		if !add(n, unit) {
+			return 0, false
+		}
+		if frac != "" {
+			f, _ := strconv.ParseUint(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+			if !add(f, 1) {
+				return 0, false
+			}
+		}
+		s = s[1:]
+	}
+
+	if neg {
+		return time.Duration(-total), true
+	}
+	return time.Duration(total), true
+}
+
+// AsTime returns a function that parses the value of a [String] Value as a
+// time using [time.Parse] with the given layout. If the value has the reserved
+// (date-time) type annotation, it is parsed as [time.RFC3339] first, falling
+// back to layout. The function returns an error if v is of any other kind or
+// cannot be parsed.
+func AsTime(layout string) func(Value) (time.Time, error) {
+	return func(v Value) (time.Time, error) {
+		if v.kind != String {
+			return time.Time{}, fmt.Errorf("kdl.AsTime: expected a string, got %s", v.kind)
+		}
+		s := v.raw.(string)
+		if v.typeValid && v.typ == "date-time" && layout != time.RFC3339 {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				return t, nil
+			}
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("kdl.AsTime: cannot parse %q with layout %q", s, layout)
+		}
+		return t, nil
+	}
+}
+
+// AsRFC3339 parses the value of a [String] Value as a [time.RFC3339] time. It
+// is equivalent to AsTime(time.RFC3339).
+func AsRFC3339(v Value) (time.Time, error) {
+	return AsTime(time.RFC3339)(v)
+}
+
+// AsBytes returns the contents of a [String] Value as bytes. If the value has a
+// (base64) type annotation, its contents are decoded as standard base64, and an
+// error is returned if they are not valid base64; otherwise the raw bytes of
+// the string are returned. It returns an error if v is of any other kind.
+func AsBytes(v Value) ([]byte, error) {
+	if v.kind != String {
+		return nil, fmt.Errorf("kdl.AsBytes: expected a string, got %s", v.kind)
+	}
+	s := v.raw.(string)
+	if !v.typeValid || v.typ != "base64" {
+		return []byte(s), nil
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("kdl.AsBytes: invalid base64 string %q: %w", s, err)
+	}
+	return b, nil
+}
+
+// AsEnum returns a function that returns the value of a [String] Value if it is
+// one of allowed, and an error listing the allowed values otherwise. It returns
+// an error if v is of any other kind.
+func AsEnum(allowed ...string) func(Value) (string, error) {
+	return func(v Value) (string, error) {
+		if v.kind != String {
+			return "", fmt.Errorf("kdl.AsEnum: expected a string, got %s", v.kind)
+		}
+		s := v.raw.(string)
+		if !slices.Contains(allowed, s) {
+			quoted := make([]string, len(allowed))
+			for i, a := range allowed {
+				quoted[i] = strconv.Quote(a)
+			}
+			return "", fmt.Errorf("kdl.AsEnum: %q is not one of %s", s, strings.Join(quoted, ", "))
+		}
+		return s, nil
+	}
+}
+
+// InRange returns a function that wraps the conversion function fn, returning
+// an error if the converted value is not within the inclusive range [lo, hi].
+// For example, InRange(uint(1), 65535)(AsUint) accepts valid port numbers.
+func InRange[T cmp.Ordered](lo, hi T) func(fn func(Value) (T, error)) func(Value) (T, error) {
+	return func(fn func(Value) (T, error)) func(Value) (T, error) {
+		return func(v Value) (T, error) {
+			var zero T
+			r, err := fn(v)
+			if err != nil {
+				return zero, err
+			}
+			if r < lo || r > hi {
+				return zero, fmt.Errorf("kdl.InRange: %v is not between %v and %v", r, lo, hi)
+			}
+			return r, nil
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package kdl
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
+	"reflect"
+	"strings"
+	"time"
 )
 
 type keyType interface{ ~string | ~int }
@@ -72,6 +77,45 @@ func Set[K keyType](node *Node, key K, value Value) {
 	}
 }
 
+// GetAnnotation gets the type annotation of an argument or property of a KDL
+// node, depending on the type of the key as in [Get]. It returns the
+// annotation and whether the value has one, or an error wrapping [ErrNotFound]
+// if the key is missing.
+//
+// GetAnnotation panics if node is nil, or if K resolves to a type other than
+// ~string or ~int at runtime.
+func GetAnnotation[K keyType](node *Node, key K) (string, bool, error) {
+	if node == nil {
+		panic("kdl.GetAnnotation: nil node")
+	}
+
+	v := Get(node, key)
+	if v == nil {
+		return "", false, fmt.Errorf("%w: node %s has no entry %v", ErrNotFound, node.name, key)
+	}
+	ty, ok := v.TypeAnnotation()
+	return ty, ok, nil
+}
+
+// SetAnnotation sets the type annotation of an existing argument or property
+// of a KDL node to ty, depending on the type of the key as in [Set], keeping
+// its value. It returns an error wrapping [ErrNotFound] if the key is missing.
+//
+// SetAnnotation panics if node is nil, or if K resolves to a type other than
+// ~string or ~int at runtime.
+func SetAnnotation[K keyType](node *Node, key K, ty string) error {
+	if node == nil {
+		panic("kdl.SetAnnotation: nil node")
+	}
+
+	v := Get(node, key)
+	if v == nil {
+		return fmt.Errorf("%w: node %s has no entry %v", ErrNotFound, node.name, key)
+	}
+	Set(node, key, v.WithTypeAnnotation(ty, true))
+	return nil
+}
+
 // GetKV gets the first child with the given name from the KDL document and
 // returns its first argument.
 //
@@ -94,6 +138,260 @@ func GetKV(document *Document, name string) (*Value, error) {
 	return &v, nil
 }
 
+// GetOr gets an argument or property from a KDL node, depending on the type of
+// the key (integer index for arguments as with [Node.GetArgument], string for
+// properties as with [Node.GetProperty]), converts it with fn, and returns the
+// result. If the key is missing, GetOr returns def and a nil error without
+// calling fn.
+//
+// Conversion errors are never swallowed: if fn returns an error, GetOr returns
+// def along with that error. Use [GetOrDefaultOnError] to get def in that case
+// too.
+//
+// GetOr panics if node is nil, or if K resolves to a type other than ~string or
+// ~int at runtime.
+func GetOr[K keyType, R any](node *Node, key K, fn func(Value) (R, error), def R) (R, error) {
+	if node == nil {
+		panic("kdl.GetOr: nil node")
+	}
+
+	v, ok := getKey("kdl.GetOr", node, key)
+	if !ok {
+		return def, nil
+	}
+	r, err := fn(v)
+	if err != nil {
+		return def, err
+	}
+	return r, nil
+}
+
+// GetOrDefaultOnError is like [GetOr], but swallows conversion errors: it
+// returns def both when the key is missing and when fn returns an error, and
+// the converted value otherwise. It suits optional settings where a malformed
+// value should fall back to the default rather than be reported.
+//
+// GetOrDefaultOnError panics if node is nil, or if K resolves to a type other
+// than ~string or ~int at runtime.
+func GetOrDefaultOnError[K keyType, R any](node *Node, key K, fn func(Value) (R, error), def R) R {
+	if node == nil {
+		panic("kdl.GetOrDefaultOnError: nil node")
+	}
+
+	v, ok := getKey("kdl.GetOrDefaultOnError", node, key)
+	if !ok {
+		return def
+	}
+	r, err := fn(v)
+	if err != nil {
+		return def
+	}
+	return r
+}
+
+// getKey gets the argument or property of node named by key and whether it
+// exists, panicking with a message prefixed by funcName if K resolves to a
+// type other than ~string or ~int.
+func getKey[K keyType](funcName string, node *Node, key K) (Value, bool) {
+	switch key := any(key).(type) {
+	case string:
+		return node.GetProperty(key)
+	case int:
+		return node.GetArgument(key)
+	default:
+		panic(fmt.Sprintf("%s: unsupported key type %T", funcName, key))
+	}
+}
+
+// GetKVOr gets the first argument of the first child with the given name from
+// the KDL document like [Document.GetKV], converts it with fn, and returns the
+// result. If no such child exists or it has no arguments, GetKVOr returns def
+// and a nil error without calling fn.
+//
+// Other errors are never swallowed: if the child has more than one argument or
+// fn returns an error, GetKVOr returns def along with that error. GetKVOr
+// panics if document is nil.
+func GetKVOr[R any](document *Document, name string, fn func(Value) (R, error), def R) (R, error) {
+	if document == nil {
+		panic("kdl.GetKVOr: nil document")
+	}
+
+	v, err := document.GetKV(name)
+	if errors.Is(err, ErrNotFound) || (err == nil && !v.IsValid()) {
+		return def, nil
+	}
+	if err != nil {
+		return def, err
+	}
+	r, err := fn(v)
+	if err != nil {
+		return def, err
+	}
+	return r, nil
+}
+
+// FirstArg converts the first argument of node with fn and returns the result.
+// It is the recommended way to read single-value nodes such as "port 22":
+//
+//	port, err := kdl.FirstArg(node, kdl.AsUint)
+//
+// If the node has no arguments, FirstArg returns an error wrapping
+// [ErrNotFound] without calling fn. Any further arguments are ignored.
+// FirstArg panics if node is nil.
+func FirstArg[R any](node *Node, fn func(Value) (R, error)) (R, error) {
+	if node == nil {
+		panic("kdl.FirstArg: nil node")
+	}
+
+	if len(node.args) == 0 {
+		var zero R
+		return zero, fmt.Errorf("%w: node %s has no arguments", ErrNotFound, node.name)
+	}
+	return fn(node.args[0])
+}
+
+// SingleAs converts the only argument of node, as returned by [Node.Single],
+// with fn and returns the result:
+//
+//	port, err := kdl.SingleAs(node, kdl.AsUint)
+//
+// If node does not have exactly one argument, SingleAs returns the error from
+// Single without calling fn. SingleAs panics if node is nil.
+func SingleAs[R any](node *Node, fn func(Value) (R, error)) (R, error) {
+	if node == nil {
+		panic("kdl.SingleAs: nil node")
+	}
+
+	v, err := node.Single()
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+	return fn(v)
+}
+
+// CastAll converts each of values with fn, a conversion function such as
+// [AsUint], and returns the results in order. It stops at the first conversion
+// error and returns it, annotated with the index of the offending value. Use
+// [CastAllPartial] to convert every value regardless of errors.
+func CastAll[R any](values []Value, fn func(Value) (R, error)) ([]R, error) {
+	return castEach(values, fn, func(i int, err error) error {
+		return fmt.Errorf("kdl.CastAll: value %d: %w", i, err)
+	})
+}
+
+// castEach converts each of values with fn, stopping at the first error, which
+// is returned as annotated by wrap with the index of the offending value.
+func castEach[R any](values []Value, fn func(Value) (R, error), wrap func(i int, err error) error) ([]R, error) {
+	out := make([]R, len(values))
+	for i, v := range values {
+		r, err := fn(v)
+		if err != nil {
+			return nil, wrap(i, err)
+		}
+		out[i] = r
+	}
+	return out, nil
+}
+
+// CastAllPartial is like [CastAll], but converts every value instead of
+// stopping at the first error, so all bad values can be reported at once.
+//
+// Both returned slices are aligned with values: out[i] is the result of
+// converting values[i], or the zero value of R if that failed, in which case
+// errs[i] holds the error returned by fn. If every conversion succeeds, errs is
+// nil; otherwise it has the same length as values, with nil entries for the
+// values that converted successfully.
+func CastAllPartial[R any](values []Value, fn func(Value) (R, error)) (out []R, errs []error) {
+	out = make([]R, len(values))
+	for i, v := range values {
+		r, err := fn(v)
+		if err != nil {
+			if errs == nil {
+				errs = make([]error, len(values))
+			}
+			errs[i] = err
+			continue
+		}
+		out[i] = r
+	}
+	return out, errs
+}
+
+// Args converts every argument of node with fn, like [CastAll], and returns
+// the results in order. The error for a failed conversion identifies the
+// argument by index. Args returns nil and no error if node is nil or has no
+// arguments.
+func Args[R any](node *Node, fn func(Value) (R, error)) ([]R, error) {
+	return castArgs("kdl.Args", node, fn)
+}
+
+func castArgs[R any](funcName string, node *Node, fn func(Value) (R, error)) ([]R, error) {
+	if node == nil || len(node.args) == 0 {
+		return nil, nil
+	}
+	return castEach(node.args, fn, func(i int, err error) error {
+		return fmt.Errorf("%s: node %s: argument %d: %w", funcName, node.name, i, err)
+	})
+}
+
+// AsStrings returns the arguments of node as strings, for list nodes such as
+// `tags a b c`. It returns an error identifying the first argument that is not
+// a [String] by index. Like [Args], it returns nil and no error if node is nil
+// or has no arguments.
+func AsStrings(node *Node) ([]string, error) {
+	return castArgs("kdl.AsStrings", node, func(v Value) (string, error) {
+		if v.kind != String {
+			return "", fmt.Errorf("expected a string, got %s", v.kind)
+		}
+		return v.raw.(string), nil
+	})
+}
+
+// AsInts is like [AsStrings], but requires every argument to be an [Int].
+func AsInts(node *Node) ([]int, error) {
+	return castArgs("kdl.AsInts", node, func(v Value) (int, error) {
+		if v.kind != Int {
+			return 0, fmt.Errorf("expected an integer, got %s", v.kind)
+		}
+		return v.raw.(int), nil
+	})
+}
+
+// AsFloat64s is like [AsStrings], but converts every argument with
+// [AsNumber], so integer and float arguments can be mixed.
+func AsFloat64s(node *Node) ([]float64, error) {
+	return castArgs("kdl.AsFloat64s", node, AsNumber)
+}
+
+// AsBools is like [AsStrings], but requires every argument to be a [Bool].
+func AsBools(node *Node) ([]bool, error) {
+	return castArgs("kdl.AsBools", node, func(v Value) (bool, error) {
+		if v.kind != Bool {
+			return false, fmt.Errorf("expected a bool, got %s", v.kind)
+		}
+		return v.raw.(bool), nil
+	})
+}
+
+// Props converts the value of every property of node with fn, like [CastAll].
+// The results are in the order of [Node.PropertyOrder], so the i-th result is
+// the value of the i-th key. The error for a failed conversion identifies the
+// property by key. Props returns nil and no error if node is nil or has no
+// properties.
+func Props[R any](node *Node, fn func(Value) (R, error)) ([]R, error) {
+	if node == nil || len(node.propOrder) == 0 {
+		return nil, nil
+	}
+	values := make([]Value, len(node.propOrder))
+	for i, key := range node.propOrder {
+		values[i] = node.props[key]
+	}
+	return castEach(values, fn, func(i int, err error) error {
+		return fmt.Errorf("kdl.Props: node %s: property %s: %w", node.name, node.propOrder[i], err)
+	})
+}
+
 type intoValue interface {
 	~string |
 		~int | ~int16 | ~int32 | ~int64 |
@@ -101,6 +399,8 @@ type intoValue interface {
 		~float32 | ~float64 |
 		~bool |
 		~*big.Int | ~*big.Float |
+		~[]byte |
+		time.Time |
 		~*Value | any
 }
 
@@ -126,8 +426,18 @@ func NewValue[T intoValue](v T) Value {
 //   - float32, float64 (wrapped as [Float])
 //   - bool (wrapped as [Bool])
 //   - *big.Int, *big.Float (wrapped as [BigInt] and [BigFloat], respectively)
+//   - []byte (wrapped as a base64 [String], as with [NewBytes])
+//   - [time.Time] (wrapped as a (date-time) [String], as with [NewTime])
+//   - [time.Duration] (wrapped as a (duration) [String], as with [NewDuration])
+//   - [json.Number] (wrapped as [Int], [Float], [BigInt], or [BigFloat], as
+//     with [DocumentFromJSON])
+//   - Value (used as-is)
 //   - *Value (if the pointer is nil, it is treated as a KDL [Null]; otherwise,
 //     the pointed-to Value is used)
+//
+// Types defined with one of the string, integer, float, or bool types above
+// as their underlying type, such as time.Month, are wrapped like their
+// underlying type.
 func TryNewValue[T intoValue](v T) (Value, error) {
 	switch v := any(v).(type) {
 	case ValueMarshaler:
@@ -176,12 +486,43 @@ func TryNewValue[T intoValue](v T) (Value, error) {
 		return NewBigInt(v), nil
 	case *big.Float:
 		return NewBigFloat(v), nil
+	case []byte:
+		return NewBytes(v), nil
+	case json.Number:
+		val, ok := newNumber(v.String(), 10, strings.ContainsAny(v.String(), ".eE"))
+		if !ok {
+			return Value{}, fmt.Errorf("kdl.NewValue: invalid json.Number %q", v)
+		}
+		return val, nil
+	case time.Time:
+		return NewTime(v), nil
+	case time.Duration:
+		return NewDuration(v), nil
+	case Value:
+		return v, nil
 	case *Value:
 		if v == nil {
 			return NewNull(), nil
 		}
 		return *v, nil
-	default:
-		return Value{}, fmt.Errorf("kdl.NewValue: unsupported type %T", v)
 	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return NewString(rv.String()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return TryNewValue(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return TryNewValue(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return NewFloat(rv.Float()), nil
+	case reflect.Bool:
+		return NewBool(rv.Bool()), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return NewBytes(rv.Bytes()), nil
+		}
+	}
+	return Value{}, fmt.Errorf("kdl.NewValue: unsupported type %T", v)
 }
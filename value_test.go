@@ -0,0 +1,669 @@
+package kdl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValuesEqual(t *testing.T) {
+	big5 := NewBigInt(big.NewInt(5))
+	bigHalf := NewBigFloat(big.NewFloat(1.5))
+
+	tests := []struct {
+		name       string
+		a, b       Value
+		equal      bool
+		ignoreType bool
+	}{
+		{"same string", NewString("a"), NewString("a"), true, true},
+		{"different string", NewString("a"), NewString("b"), false, false},
+		{"int and bigint", NewInt(5), big5, true, true},
+		{"int and different bigint", NewInt(6), big5, false, false},
+		{"float and bigfloat", NewFloat(1.5), bigHalf, true, true},
+		{"int and float", NewInt(1), NewFloat(1), false, false},
+		{"bigint and bigfloat", big5, NewBigFloat(big.NewFloat(5)), false, false},
+		{"nan", NewFloat(math.NaN()), NewFloat(math.NaN()), false, false},
+		{"nan and bigfloat", NewFloat(math.NaN()), bigHalf, false, false},
+		{"null", NewNull(), NewNull(), true, true},
+		{"bool", NewBool(true), NewBool(false), false, false},
+		{"string and int", NewString("5"), NewInt(5), false, false},
+		{"same type annotation", NewInt(5).WithTypeAnnotation("u8", true), big5.WithTypeAnnotation("u8", true), true, true},
+		{"different type annotation", NewInt(5).WithTypeAnnotation("u8", true), NewInt(5).WithTypeAnnotation("i8", true), false, true},
+		{"missing type annotation", NewInt(5).WithTypeAnnotation("u8", true), big5, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValuesEqual(tt.a, tt.b); got != tt.equal {
+				t.Errorf("ValuesEqual() = %v, want %v", got, tt.equal)
+			}
+			if got := ValuesEqual(tt.b, tt.a); got != tt.equal {
+				t.Errorf("ValuesEqual() (swapped) = %v, want %v", got, tt.equal)
+			}
+			if got := ValuesEqualIgnoreType(tt.a, tt.b); got != tt.ignoreType {
+				t.Errorf("ValuesEqualIgnoreType() = %v, want %v", got, tt.ignoreType)
+			}
+		})
+	}
+}
+
+func TestGetOr(t *testing.T) {
+	doc, err := ParseString(`
+server "main" port=8080 host=#true
+timeout 30
+retries
+multi 1 2
+`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	asInt := func(v Value) (int, error) {
+		if v.Kind() != Int {
+			return 0, fmt.Errorf("expected an integer, got %s", v.Kind())
+		}
+		return v.Int(), nil
+	}
+
+	server := doc.GetNode("server")
+	if got, err := GetOr(server, "port", asInt, 80); got != 8080 || err != nil {
+		t.Errorf("GetOr(port) = %v, %v; want 8080, nil", got, err)
+	}
+	if got, err := GetOr(server, "missing", asInt, 80); got != 80 || err != nil {
+		t.Errorf("GetOr(missing) = %v, %v; want 80, nil", got, err)
+	}
+	if got, err := GetOr(server, 3, asInt, 80); got != 80 || err != nil {
+		t.Errorf("GetOr(3) = %v, %v; want 80, nil", got, err)
+	}
+	if got, err := GetOr(server, "host", asInt, 80); got != 80 || err == nil {
+		t.Errorf("GetOr(host) = %v, %v; want 80 and an error", got, err)
+	}
+	if got, err := GetOr(server, 0, asInt, 80); got != 80 || err == nil {
+		t.Errorf("GetOr(0) = %v, %v; want 80 and an error", got, err)
+	}
+
+	if got := GetOrDefaultOnError(server, "port", asInt, 80); got != 8080 {
+		t.Errorf("GetOrDefaultOnError(port) = %v, want 8080", got)
+	}
+	if got := GetOrDefaultOnError(server, "missing", asInt, 80); got != 80 {
+		t.Errorf("GetOrDefaultOnError(missing) = %v, want 80", got)
+	}
+	if got := GetOrDefaultOnError(server, "host", asInt, 80); got != 80 {
+		t.Errorf("GetOrDefaultOnError(host) = %v, want 80", got)
+	}
+	if got := GetOrDefaultOnError(server, 0, asInt, 80); got != 80 {
+		t.Errorf("GetOrDefaultOnError(0) = %v, want 80", got)
+	}
+
+	if got, err := GetKVOr(doc, "timeout", asInt, 10); got != 30 || err != nil {
+		t.Errorf("GetKVOr(timeout) = %v, %v; want 30, nil", got, err)
+	}
+	if got, err := GetKVOr(doc, "missing", asInt, 10); got != 10 || err != nil {
+		t.Errorf("GetKVOr(missing) = %v, %v; want 10, nil", got, err)
+	}
+	if got, err := GetKVOr(doc, "retries", asInt, 10); got != 10 || err != nil {
+		t.Errorf("GetKVOr(retries) = %v, %v; want 10, nil", got, err)
+	}
+	if got, err := GetKVOr(doc, "multi", asInt, 10); got != 10 || err == nil {
+		t.Errorf("GetKVOr(multi) = %v, %v; want 10 and an error", got, err)
+	}
+	if got, err := GetKVOr(doc, "server", asInt, 10); got != 10 || err == nil {
+		t.Errorf("GetKVOr(server) = %v, %v; want 10 and an error", got, err)
+	}
+}
+
+func TestAsUint64(t *testing.T) {
+	maxUint64 := new(big.Int).SetUint64(math.MaxUint64)
+	overflow := new(big.Int).Add(maxUint64, big.NewInt(1))
+
+	tests := []struct {
+		v    Value
+		want uint64
+		err  bool
+	}{
+		{NewInt(0), 0, false},
+		{NewInt(8080), 8080, false},
+		{NewInt(math.MaxInt), math.MaxInt, false},
+		{NewInt(-1), 0, true},
+		{NewBigInt(maxUint64), math.MaxUint64, false},
+		{NewBigInt(overflow), 0, true},
+		{NewBigInt(big.NewInt(-5)), 0, true},
+		{NewFloat(1), 0, true},
+		{NewString("1"), 0, true},
+		{NewNull(), 0, true},
+	}
+	for _, tt := range tests {
+		got, err := AsUint64(tt.v)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("AsUint64(%v) = %d, %v; want %d, error %v", tt.v, got, err, tt.want, tt.err)
+		}
+	}
+
+	if got, err := AsUint(NewInt(42)); got != 42 || err != nil {
+		t.Errorf("AsUint(42) = %d, %v; want 42, nil", got, err)
+	}
+	if _, err := AsUint(NewInt(-42)); err == nil {
+		t.Error("AsUint(-42) succeeded, want an error")
+	}
+}
+
+func TestAsNumber(t *testing.T) {
+	exact := new(big.Int).Lsh(big.NewInt(1), 70)
+	inexact := new(big.Int).Add(exact, big.NewInt(1))
+	huge, _ := new(big.Float).SetString("1e400")
+	tiny, _ := new(big.Float).SetString("1e-400")
+
+	tests := []struct {
+		v    Value
+		want float64
+		err  bool
+	}{
+		{NewInt(1), 1, false},
+		{NewInt(-7), -7, false},
+		{NewInt(1<<53 + 1), 0, true},
+		{NewFloat(1.5), 1.5, false},
+		{NewBigInt(exact), math.Ldexp(1, 70), false},
+		{NewBigInt(inexact), 0, true},
+		{NewBigFloat(big.NewFloat(2.5)), 2.5, false},
+		{NewBigFloat(huge), 0, true},
+		{NewBigFloat(tiny), 0, true},
+		{NewString("1"), 0, true},
+		{NewBool(true), 0, true},
+	}
+	for _, tt := range tests {
+		got, err := AsNumber(tt.v)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("AsNumber(%v) = %v, %v; want %v, error %v", tt.v, got, err, tt.want, tt.err)
+		}
+	}
+
+	bigTests := []struct {
+		v    Value
+		want *big.Float
+	}{
+		{NewInt(3), big.NewFloat(3)},
+		{NewBigInt(inexact), new(big.Float).SetInt(inexact)},
+		{NewFloat(0.25), big.NewFloat(0.25)},
+		{NewBigFloat(huge), huge},
+	}
+	for _, tt := range bigTests {
+		got, err := AsBigNumber(tt.v)
+		if err != nil || got.Cmp(tt.want) != 0 {
+			t.Errorf("AsBigNumber(%v) = %v, %v; want %s", tt.v, got, err, tt.want.Text('g', -1))
+		}
+	}
+	if _, err := AsBigNumber(NewFloat(math.NaN())); err == nil {
+		t.Error("AsBigNumber(NaN) succeeded, want an error")
+	}
+	if _, err := AsBigNumber(NewString("1")); err == nil {
+		t.Error(`AsBigNumber("1") succeeded, want an error`)
+	}
+}
+
+func TestAsDuration(t *testing.T) {
+	tests := []struct {
+		v    Value
+		unit time.Duration
+		want time.Duration
+		err  bool
+	}{
+		{NewString("30s"), time.Nanosecond, 30 * time.Second, false},
+		{NewString("1h30m"), time.Second, 90 * time.Minute, false},
+		{NewString("30 seconds"), time.Nanosecond, 0, true},
+		{NewInt(1500), time.Nanosecond, 1500, false},
+		{NewInt(1500), time.Millisecond, 1500 * time.Millisecond, false},
+		{NewInt(-2), time.Second, -2 * time.Second, false},
+		{NewInt(math.MaxInt64), time.Second, 0, true},
+		{NewFloat(1.5), time.Second, 0, true},
+		{NewNull(), time.Second, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := AsDurationUnit(tt.unit)(tt.v)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("AsDurationUnit(%s)(%v) = %v, %v; want %v, error %v", tt.unit, tt.v, got, err, tt.want, tt.err)
+		}
+	}
+
+	if got, err := AsDuration(NewInt(5)); got != 5 || err != nil {
+		t.Errorf("AsDuration(5) = %v, %v; want 5ns, nil", got, err)
+	}
+	if _, err := AsDuration(NewString("soon")); err == nil || !strings.Contains(err.Error(), `"soon"`) {
+		t.Errorf("AsDuration(soon) error = %v, want an error mentioning the input", err)
+	}
+}
+
+func TestAsTime(t *testing.T) {
+	want := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		v      Value
+		layout string
+		want   time.Time
+		err    bool
+	}{
+		{NewString("2024-03-01T12:30:00Z"), time.RFC3339, want, false},
+		{NewString("2024-03-01 12:30"), "2006-01-02 15:04", want, false},
+		{NewString("2024-03-01T12:30:00Z"), "2006-01-02 15:04", time.Time{}, true},
+		{NewString("2024-03-01T12:30:00Z").WithTypeAnnotation("date-time", true), "2006-01-02 15:04", want, false},
+		{NewString("2024-03-01 12:30").WithTypeAnnotation("date-time", true), "2006-01-02 15:04", want, false},
+		{NewString("yesterday"), time.RFC3339, time.Time{}, true},
+		{NewInt(1709296200), time.RFC3339, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := AsTime(tt.layout)(tt.v)
+		if (err != nil) != tt.err || !got.Equal(tt.want) {
+			t.Errorf("AsTime(%q)(%v) = %v, %v; want %v, error %v", tt.layout, tt.v, got, err, tt.want, tt.err)
+		}
+	}
+
+	if got, err := AsRFC3339(NewString("2024-03-01T12:30:00Z")); !got.Equal(want) || err != nil {
+		t.Errorf("AsRFC3339() = %v, %v; want %v, nil", got, err, want)
+	}
+	_, err := AsTime("2006-01-02")(NewString("March 1"))
+	if err == nil || !strings.Contains(err.Error(), `"March 1"`) || !strings.Contains(err.Error(), `"2006-01-02"`) {
+		t.Errorf("AsTime() error = %v, want an error mentioning the input and layout", err)
+	}
+}
+
+func TestAsBytes(t *testing.T) {
+	data := []byte{0x00, 0xff, 'k', 'd', 'l'}
+	v := NewBytes(data)
+	if ty, ok := v.TypeAnnotation(); !ok || ty != "base64" || v.String() != "AP9rZGw=" {
+		t.Fatalf("NewBytes() = (%s, %v)%q, want (base64)\"AP9rZGw=\"", ty, ok, v.String())
+	}
+
+	tests := []struct {
+		v    Value
+		want string
+		err  bool
+	}{
+		{v, string(data), false},
+		{NewString("AP9rZGw="), "AP9rZGw=", false},
+		{NewString("").WithTypeAnnotation("base64", true), "", false},
+		{NewString("not base64!").WithTypeAnnotation("base64", true), "", true},
+		{NewInt(1), "", true},
+	}
+	for _, tt := range tests {
+		got, err := AsBytes(tt.v)
+		if (err != nil) != tt.err || string(got) != tt.want {
+			t.Errorf("AsBytes(%v) = %q, %v; want %q, error %v", tt.v, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestAsEnum(t *testing.T) {
+	mode := AsEnum("fast", "safe")
+	if got, err := mode(NewString("safe")); got != "safe" || err != nil {
+		t.Errorf("AsEnum()(safe) = %q, %v; want safe, nil", got, err)
+	}
+	_, err := mode(NewString("reckless"))
+	if err == nil || err.Error() != `kdl.AsEnum: "reckless" is not one of "fast", "safe"` {
+		t.Errorf("AsEnum()(reckless) error = %v", err)
+	}
+	if _, err := mode(NewInt(1)); err == nil {
+		t.Error("AsEnum()(1) succeeded, want an error")
+	}
+
+	node := NewNode("server").AddProperty("mode", NewString("fast"))
+	if got, err := GetOr(node, "mode", mode, "safe"); got != "fast" || err != nil {
+		t.Errorf("GetOr(mode) = %q, %v; want fast, nil", got, err)
+	}
+}
+
+func TestInRange(t *testing.T) {
+	port := InRange[uint](1, 65535)(AsUint)
+	tests := []struct {
+		v    Value
+		want uint
+		err  string
+	}{
+		{NewInt(1), 1, ""},
+		{NewInt(8080), 8080, ""},
+		{NewInt(65535), 65535, ""},
+		{NewInt(0), 0, "kdl.InRange: 0 is not between 1 and 65535"},
+		{NewInt(65536), 0, "kdl.InRange: 65536 is not between 1 and 65535"},
+		{NewInt(-1), 0, "kdl.AsUint64: negative integer -1"},
+	}
+	for _, tt := range tests {
+		got, err := port(tt.v)
+		var msg string
+		if err != nil {
+			msg = err.Error()
+		}
+		if got != tt.want || msg != tt.err {
+			t.Errorf("port(%v) = %d, %v; want %d, %q", tt.v, got, err, tt.want, tt.err)
+		}
+	}
+
+	// a failed conversion yields the zero value, whatever fn returned with it
+	failing := InRange(1, 10)(func(Value) (int, error) { return 5, errors.New("bad") })
+	if got, err := failing(NewInt(5)); got != 0 || err == nil {
+		t.Errorf("InRange(failing) = %d, %v; want 0 and an error", got, err)
+	}
+}
+
+func TestFirstArg(t *testing.T) {
+	doc, err := ParseString("port 22\nempty\nname \"x\" \"y\"")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if got, err := FirstArg(doc.GetNode("port"), AsUint); got != 22 || err != nil {
+		t.Errorf("FirstArg(port) = %v, %v; want 22, nil", got, err)
+	}
+	if _, err := FirstArg(doc.GetNode("empty"), AsUint); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FirstArg(empty) error = %v, want ErrNotFound", err)
+	}
+	if _, err := FirstArg(doc.GetNode("name"), AsUint); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("FirstArg(name) error = %v, want a conversion error", err)
+	}
+	if got, err := FirstArg(doc.GetNode("name"), AsEnum("x")); got != "x" || err != nil {
+		t.Errorf("FirstArg(name) = %q, %v; want x, nil", got, err)
+	}
+}
+
+func TestSingle(t *testing.T) {
+	doc, err := ParseString("port 22 proto=tcp { x; }\nempty\nname \"x\" \"y\"")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if v, err := doc.GetNode("port").Single(); err != nil || v.Int() != 22 {
+		t.Errorf("Single(port) = %v, %v; want 22, nil", v, err)
+	}
+	if v, err := doc.GetNode("empty").Single(); !errors.Is(err, ErrNotFound) || v.IsValid() {
+		t.Errorf("Single(empty) = %v, %v; want zero Value, ErrNotFound", v, err)
+	}
+	if v, err := doc.GetNode("name").Single(); err == nil || errors.Is(err, ErrNotFound) || v.IsValid() {
+		t.Errorf("Single(name) = %v, %v; want zero Value and an arity error", v, err)
+	}
+
+	if got, err := SingleAs(doc.GetNode("port"), AsUint); got != 22 || err != nil {
+		t.Errorf("SingleAs(port) = %v, %v; want 22, nil", got, err)
+	}
+	called := false
+	fn := func(v Value) (uint, error) { called = true; return AsUint(v) }
+	if _, err := SingleAs(doc.GetNode("empty"), fn); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SingleAs(empty) error = %v, want ErrNotFound", err)
+	}
+	if _, err := SingleAs(doc.GetNode("name"), fn); err == nil || !strings.Contains(err.Error(), "exactly one argument") {
+		t.Errorf("SingleAs(name) error = %v, want an arity error", err)
+	}
+	if called {
+		t.Error("SingleAs called fn for a node without exactly one argument")
+	}
+	if _, err := SingleAs(NewNode("s").AddArgument(NewString("x")), AsUint); err == nil {
+		t.Error("SingleAs(s) error = nil, want a conversion error")
+	}
+}
+
+func TestGetSetAnnotation(t *testing.T) {
+	doc, err := ParseString(`node (u8)5 "plain" size=(u16)80 name=x`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	n := doc.Nodes[0]
+
+	if ty, ok, err := GetAnnotation(n, 0); ty != "u8" || !ok || err != nil {
+		t.Errorf("GetAnnotation(0) = %q, %v, %v; want u8, true, nil", ty, ok, err)
+	}
+	if ty, ok, err := GetAnnotation(n, "name"); ty != "" || ok || err != nil {
+		t.Errorf("GetAnnotation(name) = %q, %v, %v; want \"\", false, nil", ty, ok, err)
+	}
+	if _, _, err := GetAnnotation(n, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAnnotation(2) error = %v, want ErrNotFound", err)
+	}
+
+	if err := SetAnnotation(n, 1, "tag"); err != nil {
+		t.Errorf("SetAnnotation(1) error = %v", err)
+	}
+	if err := SetAnnotation(n, "size", "u32"); err != nil {
+		t.Errorf("SetAnnotation(size) error = %v", err)
+	}
+	if err := SetAnnotation(n, "missing", "t"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetAnnotation(missing) error = %v, want ErrNotFound", err)
+	}
+
+	got, err := EmitToString(doc)
+	if err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+	if want := "node (u8)5 (tag)plain size=(u32)80 name=x\n"; got != want {
+		t.Errorf("Emit() = %q, want %q", got, want)
+	}
+}
+
+func TestTryNewValue(t *testing.T) {
+	type port uint16
+	type level string
+	type raw []byte
+
+	big30, _ := new(big.Int).SetString("1"+strings.Repeat("0", 30), 10)
+	tests := []struct {
+		v    any
+		want Value
+		err  bool
+	}{
+		{[]byte("kdl"), NewBytes([]byte("kdl")), false},
+		{raw("kdl"), NewBytes([]byte("kdl")), false},
+		{json.Number("42"), NewInt(42), false},
+		{json.Number("1.5"), NewFloat(1.5), false},
+		{json.Number("1" + strings.Repeat("0", 30)), NewBigInt(big30), false},
+		{json.Number("nope"), Value{}, true},
+		{NewString("as-is").WithTypeAnnotation("t", true), NewString("as-is").WithTypeAnnotation("t", true), false},
+		{port(8080), NewInt(8080), false},
+		{level("debug"), NewString("debug"), false},
+		{time.March, NewInt(3), false},
+		{struct{}{}, Value{}, true},
+		{[]string{"a"}, Value{}, true},
+		{nil, Value{}, true},
+	}
+	for _, tt := range tests {
+		got, err := TryNewValue(tt.v)
+		if (err != nil) != tt.err || (err == nil && !ValuesEqual(got, tt.want)) {
+			t.Errorf("TryNewValue(%#v) = %v, %v; want %v, error %v", tt.v, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestNewTimeAndDuration(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 500_000_000, time.UTC)
+	v := NewValue(ts)
+	if ty, ok := v.TypeAnnotation(); !ok || ty != "date-time" || v.String() != "2024-05-06T07:08:09.5Z" {
+		t.Errorf("NewValue(time) = (%s, %v)%q, want (date-time)\"2024-05-06T07:08:09.5Z\"", ty, ok, v.String())
+	}
+	if got, err := AsRFC3339(v); err != nil || !got.Equal(ts) {
+		t.Errorf("AsRFC3339(%v) = %v, %v; want %v", v, got, err, ts)
+	}
+
+	durations := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{30 * time.Second, "PT30S"},
+		{90 * time.Minute, "PT1H30M"},
+		{26*time.Hour + 1500*time.Millisecond, "PT26H1.5S"},
+		{-time.Nanosecond, "-PT0.000000001S"},
+		{math.MinInt64, "-PT2562047H47M16.854775808S"},
+		{math.MaxInt64, "PT2562047H47M16.854775807S"},
+	}
+	for _, tt := range durations {
+		v := NewValue(tt.d)
+		if ty, ok := v.TypeAnnotation(); !ok || ty != "duration" || v.String() != tt.want {
+			t.Errorf("NewValue(%v) = (%s, %v)%q, want (duration)%q", tt.d, ty, ok, v.String(), tt.want)
+		}
+		if got, err := AsDuration(v); err != nil || got != tt.d {
+			t.Errorf("AsDuration(%v) = %v, %v; want %v", v, got, err, tt.d)
+		}
+	}
+
+	parses := []struct {
+		s    string
+		want time.Duration
+		err  bool
+	}{
+		{"P1D", 24 * time.Hour, false},
+		{"P1DT2H", 26 * time.Hour, false},
+		{"+PT1,5S", 1500 * time.Millisecond, false},
+		{"1m30s", 90 * time.Second, false}, // falls back to time.ParseDuration
+		{"P", 0, true},
+		{"PT", 0, true},
+		{"P1DT", 0, true},
+		{"P1Y", 0, true},
+		{"PT1S1M", 0, true},
+		{"PT1.5M", 0, true},
+		{"PT9223372037S", 0, true},
+	}
+	for _, tt := range parses {
+		v := NewString(tt.s).WithTypeAnnotation("duration", true)
+		got, err := AsDuration(v)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("AsDuration(%v) = %v, %v; want %v, error %v", v, got, err, tt.want, tt.err)
+		}
+	}
+
+	if _, err := AsDuration(NewString("PT30S")); err == nil {
+		t.Errorf("AsDuration(\"PT30S\") without annotation succeeded, want an error")
+	}
+}
+
+func TestCastAll(t *testing.T) {
+	values := []Value{NewInt(1), NewString("two"), NewInt(3), NewInt(-4)}
+
+	if got, err := CastAll(values[:1], AsUint); err != nil || len(got) != 1 || got[0] != 1 {
+		t.Errorf("CastAll([1]) = %v, %v; want [1], nil", got, err)
+	}
+	if _, err := CastAll(values, AsUint); err == nil || !strings.Contains(err.Error(), "value 1") {
+		t.Errorf("CastAll() error = %v, want an error for value 1", err)
+	}
+
+	got, errs := CastAllPartial(values, AsUint)
+	if fmt.Sprint(got) != "[1 0 3 0]" {
+		t.Errorf("CastAllPartial() values = %v, want [1 0 3 0]", got)
+	}
+	if len(errs) != len(values) || errs[0] != nil || errs[1] == nil || errs[2] != nil || errs[3] == nil {
+		t.Errorf("CastAllPartial() errors = %v, want errors at indexes 1 and 3", errs)
+	}
+
+	if got, errs := CastAllPartial([]Value{NewInt(5)}, AsUint); errs != nil || len(got) != 1 || got[0] != 5 {
+		t.Errorf("CastAllPartial([5]) = %v, %v; want [5], nil", got, errs)
+	}
+}
+
+func TestArgsProps(t *testing.T) {
+	doc, err := ParseString("tags a b c\nports 80 \"443\"\nlimits cpu=2 mem=512\nempty")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	asString := func(v Value) (string, error) {
+		if v.Kind() != String {
+			return "", fmt.Errorf("expected a string, got %s", v.Kind())
+		}
+		return v.String(), nil
+	}
+
+	if got, err := Args(doc.GetNode("tags"), asString); err != nil || strings.Join(got, ",") != "a,b,c" {
+		t.Errorf("Args(tags) = %q, %v; want [a b c], nil", got, err)
+	}
+	if _, err := Args(doc.GetNode("ports"), AsUint); err == nil || !strings.Contains(err.Error(), "argument 1") {
+		t.Errorf("Args(ports) error = %v, want an error for argument 1", err)
+	}
+	if got, err := Args(doc.GetNode("empty"), asString); got != nil || err != nil {
+		t.Errorf("Args(empty) = %q, %v; want nil, nil", got, err)
+	}
+	if got, err := Args(nil, asString); got != nil || err != nil {
+		t.Errorf("Args(nil) = %q, %v; want nil, nil", got, err)
+	}
+
+	limits := doc.GetNode("limits")
+	if got, err := Props(limits, AsUint); err != nil || fmt.Sprint(got) != "[2 512]" {
+		t.Errorf("Props(limits) = %v, %v; want [2 512], nil", got, err)
+	}
+	if _, err := Props(limits, asString); err == nil || !strings.Contains(err.Error(), "property cpu") {
+		t.Errorf("Props(limits) error = %v, want an error for property cpu", err)
+	}
+	if got, err := Props(nil, AsUint); got != nil || err != nil {
+		t.Errorf("Props(nil) = %v, %v; want nil, nil", got, err)
+	}
+}
+
+func TestTypedArgs(t *testing.T) {
+	doc, err := ParseString("list a b c\nports 80 443\nratios 1 0.5\nflags #true #false\nmixed 1 two 3\nempty")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if got, err := AsStrings(doc.GetNode("list")); err != nil || fmt.Sprint(got) != "[a b c]" {
+		t.Errorf("AsStrings(list) = %q, %v; want [a b c], nil", got, err)
+	}
+	if got, err := AsInts(doc.GetNode("ports")); err != nil || fmt.Sprint(got) != "[80 443]" {
+		t.Errorf("AsInts(ports) = %v, %v; want [80 443], nil", got, err)
+	}
+	if got, err := AsFloat64s(doc.GetNode("ratios")); err != nil || fmt.Sprint(got) != "[1 0.5]" {
+		t.Errorf("AsFloat64s(ratios) = %v, %v; want [1 0.5], nil", got, err)
+	}
+	if got, err := AsBools(doc.GetNode("flags")); err != nil || fmt.Sprint(got) != "[true false]" {
+		t.Errorf("AsBools(flags) = %v, %v; want [true false], nil", got, err)
+	}
+	if got, err := AsInts(doc.GetNode("empty")); got != nil || err != nil {
+		t.Errorf("AsInts(empty) = %v, %v; want nil, nil", got, err)
+	}
+
+	mixed := doc.GetNode("mixed")
+	errTests := []struct {
+		name string
+		fn   func(*Node) error
+		want string
+	}{
+		{"AsStrings", func(n *Node) error { _, err := AsStrings(n); return err }, "kdl.AsStrings: node mixed: argument 0: expected a string"},
+		{"AsInts", func(n *Node) error { _, err := AsInts(n); return err }, "kdl.AsInts: node mixed: argument 1: expected an integer"},
+		{"AsFloat64s", func(n *Node) error { _, err := AsFloat64s(n); return err }, "kdl.AsFloat64s: node mixed: argument 1:"},
+		{"AsBools", func(n *Node) error { _, err := AsBools(n); return err }, "kdl.AsBools: node mixed: argument 0: expected a bool"},
+	}
+	for _, tt := range errTests {
+		if err := tt.fn(mixed); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s(mixed) error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestValueIsIntegerIsFloat(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		v              Value
+		integer, float bool
+	}{
+		{NewInt(1), true, false},
+		{NewBigInt(huge), true, false},
+		{NewFloat(1), false, true},
+		{NewBigFloat(big.NewFloat(1.5)), false, true},
+		{NewString("1"), false, false},
+		{NewBool(true), false, false},
+		{NewNull(), false, false},
+		{Value{}, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.v.IsInteger(); got != tt.integer {
+			t.Errorf("%v.IsInteger() = %v, want %v", tt.v, got, tt.integer)
+		}
+		if got := tt.v.IsFloat(); got != tt.float {
+			t.Errorf("%v.IsFloat() = %v, want %v", tt.v, got, tt.float)
+		}
+	}
+
+	doc, err := ParseString("node 1 1.0 1e3 0x10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false, false, true} {
+		if got := doc.Nodes[0].Arg(i).IsInteger(); got != want {
+			t.Errorf("arg %d IsInteger() = %v, want %v", i, got, want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package kdl
 
+import "errors"
+
 // Walk calls fn for each node in doc in depth-first pre-order. If fn returns
 // false for a node, that node's children are not visited.
 func Walk(doc *Document, fn func(node *Node, depth int) bool) {
@@ -18,3 +20,39 @@ func walkDepth(nodes []*Node, depth int, fn func(*Node, int) bool) {
 		}
 	}
 }
+
+// SkipChildren can be returned from the function passed to [WalkErr] to skip
+// the children of the current node. It is never returned by WalkErr itself.
+var SkipChildren = errors.New("skip children")
+
+// WalkErr is like [Walk], but fn returns an error. Nodes are visited in
+// depth-first pre-order: each node is visited before its children, and
+// siblings are visited in document order. The depth of top-level nodes is 0.
+//
+// If fn returns [SkipChildren], the children of that node are not visited and
+// the walk continues with its next sibling. If fn returns any other non-nil
+// error, the walk stops and WalkErr returns that error.
+func WalkErr(doc *Document, fn func(node *Node, depth int) error) error {
+	if doc == nil {
+		return nil
+	}
+	return walkDepthErr(doc.Nodes, 0, fn)
+}
+
+func walkDepthErr(nodes []*Node, depth int, fn func(*Node, int) error) error {
+	for _, n := range nodes {
+		err := fn(n, depth)
+		if err == SkipChildren {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(n.children.Nodes) > 0 {
+			if err := walkDepthErr(n.children.Nodes, depth+1, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
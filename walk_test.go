@@ -0,0 +1,55 @@
+package kdl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWalkErr(t *testing.T) {
+	doc, err := ParseString("a {\n    b {\n        c\n    }\n    d\n}\ne {\n    f\n}\ng\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	visit := func(skip, stop string) ([]string, error) {
+		var visited []string
+		err := WalkErr(doc, func(n *Node, depth int) error {
+			visited = append(visited, fmt.Sprintf("%s%d", n.Name(), depth))
+			switch n.Name() {
+			case skip:
+				return SkipChildren
+			case stop:
+				return fmt.Errorf("stopped at %s", n.Name())
+			}
+			return nil
+		})
+		return visited, err
+	}
+
+	tests := []struct {
+		name       string
+		skip, stop string
+		want       string
+		wantErr    bool
+	}{
+		{name: "all", want: "a0 b1 c2 d1 e0 f1 g0"},
+		{name: "skip children", skip: "b", want: "a0 b1 d1 e0 f1 g0"},
+		{name: "stop", stop: "d", want: "a0 b1 c2 d1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visited, err := visit(tt.skip, tt.stop)
+			if got := strings.Join(visited, " "); got != tt.want {
+				t.Errorf("visited %s, want %s", got, tt.want)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WalkErr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, SkipChildren) {
+				t.Errorf("WalkErr() returned SkipChildren")
+			}
+		})
+	}
+}